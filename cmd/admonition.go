@@ -0,0 +1,225 @@
+/*
+Copyright © 2024 Brian Greenhill <brian@briangreenhill.net>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package cmd
+
+import (
+	"bytes"
+	"regexp"
+	"strings"
+
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/parser"
+	"github.com/yuin/goldmark/renderer"
+	"github.com/yuin/goldmark/text"
+	"github.com/yuin/goldmark/util"
+)
+
+// admonitionExtension adds callout boxes to markdown, written either as
+// GitHub-style alerts:
+//
+//	> [!NOTE]
+//	> Useful information.
+//
+// or as fenced containers with an optional title:
+//
+//	:::warning Watch out
+//	Something dangerous.
+//	:::
+type admonitionExtension struct{}
+
+var kindAdmonition = ast.NewNodeKind("Admonition")
+
+type admonition struct {
+	ast.BaseBlock
+	AlertType string
+	Title     string
+}
+
+func (n *admonition) Kind() ast.NodeKind {
+	return kindAdmonition
+}
+
+func (n *admonition) Dump(source []byte, level int) {
+	ast.DumpHelper(n, source, level, map[string]string{"AlertType": n.AlertType, "Title": n.Title}, nil)
+}
+
+func newAdmonition(kind, title string) *admonition {
+	kind = strings.ToLower(kind)
+	if title == "" {
+		title = strings.ToUpper(kind[:1]) + kind[1:]
+	}
+	return &admonition{AlertType: kind, Title: title}
+}
+
+func (e *admonitionExtension) Extend(m goldmark.Markdown) {
+	m.Parser().AddOptions(
+		parser.WithBlockParsers(util.Prioritized(&containerParser{}, 100)),
+		parser.WithASTTransformers(util.Prioritized(&alertTransformer{}, 100)),
+	)
+	m.Renderer().AddOptions(
+		renderer.WithNodeRenderers(util.Prioritized(&admonitionRenderer{}, 100)),
+	)
+}
+
+var (
+	alertRe          = regexp.MustCompile(`(?i)^\[!(note|tip|important|warning|caution)\]$`)
+	containerOpenRe  = regexp.MustCompile(`^:::[ \t]*([A-Za-z]+)(?:[ \t]+(.*?))?[ \t]*$`)
+	containerCloseRe = regexp.MustCompile(`^:::[ \t]*$`)
+)
+
+// alertTransformer turns blockquotes whose first line is [!TYPE] into
+// admonitions.
+type alertTransformer struct{}
+
+func (t *alertTransformer) Transform(doc *ast.Document, reader text.Reader, pc parser.Context) {
+	source := reader.Source()
+
+	var quotes []*ast.Blockquote
+	_ = ast.Walk(doc, func(n ast.Node, entering bool) (ast.WalkStatus, error) {
+		if bq, ok := n.(*ast.Blockquote); ok && entering {
+			quotes = append(quotes, bq)
+		}
+		return ast.WalkContinue, nil
+	})
+
+	for _, bq := range quotes {
+		para, ok := bq.FirstChild().(*ast.Paragraph)
+		if !ok || para.Lines().Len() == 0 {
+			continue
+		}
+		first := para.Lines().At(0)
+		m := alertRe.FindSubmatch(bytes.TrimSpace(first.Value(source)))
+		if m == nil {
+			continue
+		}
+
+		// drop the inline nodes that make up the [!TYPE] marker line
+		for c := para.FirstChild(); c != nil; {
+			next := c.NextSibling()
+			t, ok := c.(*ast.Text)
+			if !ok || t.Segment.Start >= first.Stop {
+				break
+			}
+			para.RemoveChild(para, c)
+			c = next
+		}
+		if !para.HasChildren() {
+			bq.RemoveChild(bq, para)
+		}
+
+		adm := newAdmonition(string(m[1]), "")
+		for c := bq.FirstChild(); c != nil; {
+			next := c.NextSibling()
+			adm.AppendChild(adm, c)
+			c = next
+		}
+		bq.Parent().ReplaceChild(bq.Parent(), bq, adm)
+	}
+}
+
+// containerParser parses ::: fenced admonitions.
+type containerParser struct{}
+
+func (b *containerParser) Trigger() []byte {
+	return []byte{':'}
+}
+
+func (b *containerParser) Open(parent ast.Node, reader text.Reader, pc parser.Context) (ast.Node, parser.State) {
+	line, segment := reader.PeekLine()
+	m := containerOpenRe.FindSubmatch(bytes.TrimRight(line, "\r\n"))
+	if m == nil {
+		return nil, parser.NoChildren
+	}
+	advancePastLine(reader, line, segment)
+	return newAdmonition(string(m[1]), string(m[2])), parser.HasChildren
+}
+
+func (b *containerParser) Continue(node ast.Node, reader text.Reader, pc parser.Context) parser.State {
+	line, segment := reader.PeekLine()
+	if containerCloseRe.Match(bytes.TrimRight(line, "\r\n")) && !hasOpenFence(node, pc) {
+		advancePastLine(reader, line, segment)
+		return parser.Close
+	}
+	return parser.Continue | parser.HasChildren
+}
+
+// hasOpenFence reports whether a container or fenced code block is open
+// inside node, in which case a ::: line belongs to it rather than closing
+// node.
+func hasOpenFence(node ast.Node, pc parser.Context) bool {
+	blocks := pc.OpenedBlocks()
+	for i, b := range blocks {
+		if b.Node != node {
+			continue
+		}
+		for _, inner := range blocks[i+1:] {
+			switch inner.Node.(type) {
+			case *admonition, *ast.FencedCodeBlock:
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// advancePastLine consumes line up to its newline, if it has one, so that
+// nothing of it is left to be parsed as a paragraph.
+func advancePastLine(reader text.Reader, line []byte, segment text.Segment) {
+	n := segment.Len()
+	if bytes.HasSuffix(line, []byte("\n")) {
+		n--
+	}
+	reader.Advance(n)
+}
+
+func (b *containerParser) Close(node ast.Node, reader text.Reader, pc parser.Context) {}
+
+func (b *containerParser) CanInterruptParagraph() bool {
+	return true
+}
+
+func (b *containerParser) CanAcceptIndentedLine() bool {
+	return false
+}
+
+type admonitionRenderer struct{}
+
+func (r *admonitionRenderer) RegisterFuncs(reg renderer.NodeRendererFuncRegisterer) {
+	reg.Register(kindAdmonition, r.render)
+}
+
+func (r *admonitionRenderer) render(w util.BufWriter, source []byte, node ast.Node, entering bool) (ast.WalkStatus, error) {
+	n := node.(*admonition)
+	if !entering {
+		_, _ = w.WriteString("</div>\n")
+		return ast.WalkContinue, nil
+	}
+
+	_, _ = w.WriteString(`<div class="admonition admonition-`)
+	_, _ = w.Write(util.EscapeHTML([]byte(n.AlertType)))
+	_, _ = w.WriteString(`" role="note">` + "\n")
+	_, _ = w.WriteString(`<p class="admonition-title">`)
+	_, _ = w.Write(util.EscapeHTML([]byte(n.Title)))
+	_, _ = w.WriteString("</p>\n")
+	return ast.WalkContinue, nil
+}
@@ -0,0 +1,109 @@
+/*
+Copyright © 2024 Brian Greenhill <brian@briangreenhill.net>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package cmd
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/yuin/goldmark"
+)
+
+func renderAdmonitions(t *testing.T, src string) string {
+	t.Helper()
+	md := goldmark.New(goldmark.WithExtensions(&admonitionExtension{}))
+	var buf bytes.Buffer
+	if err := md.Convert([]byte(src), &buf); err != nil {
+		t.Fatalf("Convert() error = %v", err)
+	}
+	return buf.String()
+}
+
+func TestAdmonitions(t *testing.T) {
+	tests := []struct {
+		name string
+		src  string
+		want string
+	}{
+		{
+			name: "alert",
+			src:  "> [!NOTE]\n> Useful information.\n",
+			want: "<div class=\"admonition admonition-note\" role=\"note\">\n<p class=\"admonition-title\">Note</p>\n<p>Useful information.</p>\n</div>\n",
+		},
+		{
+			name: "alert is case insensitive",
+			src:  "> [!warning]\n> Careful.\n",
+			want: "<div class=\"admonition admonition-warning\" role=\"note\">\n<p class=\"admonition-title\">Warning</p>\n<p>Careful.</p>\n</div>\n",
+		},
+		{
+			name: "plain blockquote",
+			src:  "> [!UNKNOWN]\n> Quoted.\n",
+			want: "<blockquote>\n<p>[!UNKNOWN]\nQuoted.</p>\n</blockquote>\n",
+		},
+		{
+			name: "container",
+			src:  ":::tip\nInner **markdown**.\n:::\n\nAfter.\n",
+			want: "<div class=\"admonition admonition-tip\" role=\"note\">\n<p class=\"admonition-title\">Tip</p>\n<p>Inner <strong>markdown</strong>.</p>\n</div>\n<p>After.</p>\n",
+		},
+		{
+			name: "container with title",
+			src:  ":::warning Watch <out>\nSomething dangerous.\n:::\n",
+			want: "<div class=\"admonition admonition-warning\" role=\"note\">\n<p class=\"admonition-title\">Watch &lt;out&gt;</p>\n<p>Something dangerous.</p>\n</div>\n",
+		},
+		{
+			name: "closing fence at end of file",
+			src:  ":::note\nhello\n:::",
+			want: "<div class=\"admonition admonition-note\" role=\"note\">\n<p class=\"admonition-title\">Note</p>\n<p>hello</p>\n</div>\n",
+		},
+		{
+			name: "unclosed container",
+			src:  ":::note\nhello\n",
+			want: "<div class=\"admonition admonition-note\" role=\"note\">\n<p class=\"admonition-title\">Note</p>\n<p>hello</p>\n</div>\n",
+		},
+		{
+			name: "nested containers",
+			src:  ":::note\nouter\n:::tip\ninner\n:::\nouter again\n:::\n\nafter\n",
+			want: "<div class=\"admonition admonition-note\" role=\"note\">\n<p class=\"admonition-title\">Note</p>\n<p>outer</p>\n" +
+				"<div class=\"admonition admonition-tip\" role=\"note\">\n<p class=\"admonition-title\">Tip</p>\n<p>inner</p>\n</div>\n" +
+				"<p>outer again</p>\n</div>\n<p>after</p>\n",
+		},
+		{
+			name: "nested containers at end of file",
+			src:  ":::note\n:::tip\ninner\n:::\n:::",
+			want: "<div class=\"admonition admonition-note\" role=\"note\">\n<p class=\"admonition-title\">Note</p>\n" +
+				"<div class=\"admonition admonition-tip\" role=\"note\">\n<p class=\"admonition-title\">Tip</p>\n<p>inner</p>\n</div>\n</div>\n",
+		},
+		{
+			name: "fence inside code block",
+			src:  ":::note\n```\n:::\n```\n:::\n",
+			want: "<div class=\"admonition admonition-note\" role=\"note\">\n<p class=\"admonition-title\">Note</p>\n<pre><code>:::\n</code></pre>\n</div>\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := renderAdmonitions(t, tt.src); got != tt.want {
+				t.Errorf("render(%q) =\n%s\nwant\n%s", tt.src, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,143 @@
+/*
+Copyright © 2024 Brian Greenhill <brian@briangreenhill.net>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package cmd
+
+import (
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+)
+
+const manifestName = "manifest.json"
+
+// assetRef is what the asset template function returns: the fingerprinted
+// URL of an asset, relative to the site root, and its SRI hash.
+type assetRef struct {
+	URL       string
+	Integrity string
+}
+
+func (a assetRef) String() string {
+	return a.URL
+}
+
+// assetManifest records the fingerprinted name of every asset written to
+// the output directory, keyed by its original name.
+type assetManifest struct {
+	output *outputFiles
+
+	mu     sync.Mutex
+	assets map[string]assetRef
+}
+
+func newAssetManifest(output *outputFiles) *assetManifest {
+	return &assetManifest{output: output, assets: map[string]assetRef{}}
+}
+
+// fingerprint writes b to the output assets directory under a name that
+// includes a hash of its content, such as style.1a2b3c4d.css, and records
+// it in the manifest under name. Each name can only be used once per build.
+func (m *assetManifest) fingerprint(name string, b []byte) (assetRef, error) {
+	m.mu.Lock()
+	_, exists := m.assets[name]
+	if !exists {
+		// reserve the name so a concurrent duplicate is caught too
+		m.assets[name] = assetRef{}
+	}
+	m.mu.Unlock()
+	if exists {
+		return assetRef{}, fmt.Errorf("duplicate asset %q: another theme or content asset, bundle or stylesheet has the same name", name)
+	}
+
+	sum := sha256.Sum256(b)
+	ext := path.Ext(name)
+	hashed := fmt.Sprintf("%s.%s%s", strings.TrimSuffix(name, ext), hex.EncodeToString(sum[:4]), ext)
+
+	dst := filepath.Join(m.output.dir, assetsDirName, filepath.FromSlash(hashed))
+	if err := m.output.writeFile(dst, b); err != nil {
+		return assetRef{}, fmt.Errorf("error writing %s: %w", dst, err)
+	}
+
+	integrity := sha512.Sum384(b)
+	ref := assetRef{
+		URL:       assetsDirName + "/" + hashed,
+		Integrity: "sha384-" + base64.StdEncoding.EncodeToString(integrity[:]),
+	}
+
+	m.mu.Lock()
+	m.assets[name] = ref
+	m.mu.Unlock()
+
+	return ref, nil
+}
+
+// fingerprintFile fingerprints the file at src as name.
+func (m *assetManifest) fingerprintFile(name, src string) (assetRef, error) {
+	b, err := os.ReadFile(src)
+	if err != nil {
+		return assetRef{}, fmt.Errorf("error reading asset: %w", err)
+	}
+	return m.fingerprint(name, b)
+}
+
+// lookup returns the fingerprinted reference for an asset, for use as the
+// asset template function. An unknown asset gives the zero assetRef, so
+// templates can use {{ with asset "name" }} for optional assets.
+func (m *assetManifest) lookup(name string) (assetRef, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.assets[strings.TrimPrefix(name, "/")], nil
+}
+
+// write saves the manifest as JSON mapping original names to fingerprinted
+// paths, for tools outside of ssg that need to find assets.
+func (m *assetManifest) write() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	names := make([]string, 0, len(m.assets))
+	for name := range m.assets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	manifest := make(map[string]string, len(names))
+	for _, name := range names {
+		manifest[name] = m.assets[name].URL
+	}
+
+	b, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return err
+	}
+	return m.output.writeFile(filepath.Join(m.output.dir, assetsDirName, manifestName), append(b, '\n'))
+}
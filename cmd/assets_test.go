@@ -0,0 +1,56 @@
+/*
+Copyright © 2024 Brian Greenhill <brian@briangreenhill.net>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package cmd
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestAssetManifest(t *testing.T) {
+	m := newAssetManifest(newOutputFiles("public", nil, newMemoryFS()))
+
+	ref, err := m.fingerprint("style.css", []byte("a{color:red}"))
+	if err != nil {
+		t.Fatalf("fingerprint() error = %v", err)
+	}
+	if !strings.HasPrefix(ref.URL, "assets/style.") || !strings.HasSuffix(ref.URL, ".css") {
+		t.Errorf("fingerprint() URL = %q, want assets/style.<hash>.css", ref.URL)
+	}
+	if !strings.HasPrefix(ref.Integrity, "sha384-") {
+		t.Errorf("fingerprint() Integrity = %q, want a sha384 hash", ref.Integrity)
+	}
+
+	if got, err := m.lookup("/style.css"); err != nil || got != ref {
+		t.Errorf("lookup(/style.css) = %v, %v, want %v", got, err, ref)
+	}
+	if got, err := m.lookup("missing.css"); err != nil || got != (assetRef{}) {
+		t.Errorf("lookup(missing.css) = %v, %v, want the zero assetRef", got, err)
+	}
+
+	if _, err := m.fingerprint("style.css", []byte("b{}")); err == nil {
+		t.Error("fingerprint() of a duplicate name succeeded, want an error")
+	}
+	if got, _ := m.lookup("style.css"); got != ref {
+		t.Errorf("duplicate replaced the first asset: lookup(style.css) = %v, want %v", got, ref)
+	}
+}
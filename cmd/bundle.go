@@ -0,0 +1,130 @@
+/*
+Copyright © 2024 Brian Greenhill <brian@briangreenhill.net>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package cmd
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+
+	"github.com/tdewolff/minify/v2"
+	"github.com/tdewolff/minify/v2/css"
+	"github.com/tdewolff/minify/v2/html"
+	"github.com/tdewolff/minify/v2/js"
+	"github.com/tdewolff/minify/v2/json"
+)
+
+// bundleConfig is a theme-declared asset built by concatenating files from
+// the theme directory. Its type is taken from the extension of Name.
+type bundleConfig struct {
+	Name  string   `yaml:"name"`
+	Files []string `yaml:"files"`
+}
+
+type minifyConfig struct {
+	HTML bool
+	CSS  bool
+	JS   bool
+}
+
+func newMinifier() *minify.M {
+	m := minify.New()
+	m.AddFunc("text/css", css.Minify)
+	m.Add("text/html", &html.Minifier{KeepDocumentTags: true, KeepEndTags: true, KeepQuotes: true})
+	m.AddFuncRegexp(regexp.MustCompile(`^(application|text)/(x-)?(java|ecma)script$`), js.Minify)
+	m.AddFuncRegexp(regexp.MustCompile(`[/+]json$`), json.Minify)
+	return m
+}
+
+// buildBundles concatenates, minifies and fingerprints each bundle, writing
+// it to the output assets directory under both its plain and fingerprinted
+// names.
+func buildBundles(themeDir string, output *outputFiles, bundles []bundleConfig, cfg minifyConfig, manifest *assetManifest) error {
+	m := newMinifier()
+
+	for _, bundle := range bundles {
+		var mediaType string
+		var shouldMinify bool
+		switch path.Ext(bundle.Name) {
+		case ".css":
+			mediaType, shouldMinify = "text/css", cfg.CSS
+		case ".js":
+			mediaType, shouldMinify = "application/javascript", cfg.JS
+		default:
+			return fmt.Errorf("bundle %s: only .css and .js bundles are supported", bundle.Name)
+		}
+
+		var buf bytes.Buffer
+		for _, file := range bundle.Files {
+			b, err := readBundleFile(filepath.Join(themeDir, filepath.FromSlash(file)))
+			if err != nil {
+				return fmt.Errorf("bundle %s: %w", bundle.Name, err)
+			}
+			buf.Write(b)
+			if mediaType == "application/javascript" {
+				// guard against files that don't end their last statement
+				buf.WriteString(";")
+			}
+			buf.WriteString("\n")
+		}
+
+		out := buf.Bytes()
+		if shouldMinify {
+			minified, err := m.Bytes(mediaType, out)
+			if err != nil {
+				return fmt.Errorf("bundle %s: error minifying: %w", bundle.Name, err)
+			}
+			out = minified
+		}
+
+		fmt.Println("bundling asset: ", bundle.Name)
+		dst := filepath.Join(output.dir, assetsDirName, filepath.FromSlash(bundle.Name))
+		if err := output.writeFile(dst, out); err != nil {
+			return fmt.Errorf("bundle %s: %w", bundle.Name, err)
+		}
+		if _, err := manifest.fingerprint(bundle.Name, out); err != nil {
+			return fmt.Errorf("bundle %s: %w", bundle.Name, err)
+		}
+	}
+
+	return nil
+}
+
+// readBundleFile reads a file for a bundle, compiling it first if it is SCSS.
+func readBundleFile(file string) ([]byte, error) {
+	if filepath.Ext(file) != ".scss" {
+		return os.ReadFile(file)
+	}
+	css, _, err := compileSCSS(file, false)
+	return []byte(css), err
+}
+
+// minifyHTML minifies a generated page when HTML minification is enabled.
+func minifyHTML(m *minify.M, cfg minifyConfig, page []byte) ([]byte, error) {
+	if !cfg.HTML {
+		return page, nil
+	}
+	return m.Bytes("text/html", page)
+}
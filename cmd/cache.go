@@ -0,0 +1,207 @@
+/*
+Copyright © 2024 Brian Greenhill <brian@briangreenhill.net>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package cmd
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+)
+
+const buildCacheName = "build.json"
+
+// buildCacheVersion is part of the build key. Bump it when the cached post
+// format changes, so posts cached by an older ssg are rendered again.
+const buildCacheVersion = 2
+
+// buildCache is persisted in the cache directory between builds so that
+// unchanged posts aren't rendered again and unchanged output files aren't
+// rewritten. Rendered posts are only reused while the build key, a hash of
+// the config, theme and non-post content, stays the same.
+type buildCache struct {
+	Key   string                `json:"key"`
+	Files map[string]string     `json:"files"` // output file to content hash
+	Posts map[string]cachedPost `json:"posts"` // markdown file to its render
+
+	mu        sync.Mutex
+	prevFiles map[string]string
+	prevPosts map[string]cachedPost
+}
+
+type cachedPost struct {
+	Hash   string   `json:"hash"`
+	Post   post     `json:"post"`
+	Images []string `json:"images,omitempty"`
+}
+
+// loadBuildCache reads the cache saved by the previous build. It returns an
+// empty cache when there is none, when useCache is false, or when it can't
+// be read.
+func loadBuildCache(key string, useCache bool) *buildCache {
+	c := &buildCache{
+		Key:       key,
+		Files:     map[string]string{},
+		Posts:     map[string]cachedPost{},
+		prevFiles: map[string]string{},
+		prevPosts: map[string]cachedPost{},
+	}
+	if !useCache {
+		return c
+	}
+
+	b, err := os.ReadFile(filepath.Join(cacheDirName, buildCacheName))
+	if err != nil {
+		return c
+	}
+	var prev buildCache
+	if err := json.Unmarshal(b, &prev); err != nil {
+		fmt.Printf("warning: ignoring unreadable build cache: %v\n", err)
+		return c
+	}
+
+	if prev.Files != nil {
+		c.prevFiles = prev.Files
+	}
+	if prev.Key != key {
+		fmt.Println("templates, config or content changed, rebuilding all posts")
+	} else if prev.Posts != nil {
+		c.prevPosts = prev.Posts
+	}
+	return c
+}
+
+// post returns the cached render of the markdown file, if its content
+// hasn't changed since it was cached.
+func (c *buildCache) post(file, hash string) (cachedPost, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	cp, ok := c.prevPosts[file]
+	if !ok || cp.Hash != hash {
+		return cachedPost{}, false
+	}
+	c.Posts[file] = cp
+	return cp, true
+}
+
+func (c *buildCache) setPost(file string, cp cachedPost) {
+	c.mu.Lock()
+	c.Posts[file] = cp
+	c.mu.Unlock()
+}
+
+// unchanged reports whether the output file at path already holds content
+// with the given hash, recording the hash for the next build either way.
+func (c *buildCache) unchanged(path, hash string, size int) bool {
+	c.mu.Lock()
+	prev := c.prevFiles[path]
+	c.Files[path] = hash
+	c.mu.Unlock()
+
+	if prev != hash {
+		return false
+	}
+	info, err := os.Stat(path)
+	return err == nil && info.Size() == int64(size)
+}
+
+// forget drops the recorded hash for path, for when writing it failed.
+func (c *buildCache) forget(path string) {
+	c.mu.Lock()
+	delete(c.Files, path)
+	c.mu.Unlock()
+}
+
+func (c *buildCache) save() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	b, err := json.Marshal(c)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(cacheDirName, 0755); err != nil {
+		return err
+	}
+	return writeFileAtomic(filepath.Join(cacheDirName, buildCacheName), b)
+}
+
+// buildKey hashes everything a rendered post depends on besides its own
+// markdown: the config, the build flags, every theme file and the content
+// directory other than the posts themselves.
+func buildKey(cfg *config, themeDir string) (string, error) {
+	h := sha256.New()
+
+	b, err := json.Marshal(struct {
+		Version int
+		Config  *config
+		Drafts  bool
+		Watch   bool
+	}{buildCacheVersion, cfg, includeDrafts, watchMode})
+	if err != nil {
+		return "", err
+	}
+	h.Write(b)
+
+	postsDir := filepath.Join(cfg.ContentDir, postsDirName)
+	for _, dir := range []string{themeDir, cfg.ContentDir} {
+		var files []string
+		err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if d.IsDir() && path == postsDir {
+				return filepath.SkipDir
+			}
+			if !d.IsDir() {
+				files = append(files, path)
+			}
+			return nil
+		})
+		if err != nil && !os.IsNotExist(err) {
+			return "", fmt.Errorf("error hashing %s: %w", dir, err)
+		}
+
+		sort.Strings(files)
+		for _, file := range files {
+			b, err := os.ReadFile(file)
+			if err != nil {
+				return "", err
+			}
+			fmt.Fprintf(h, "%s\x00%d\x00", file, len(b))
+			h.Write(b)
+		}
+	}
+
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+func hashBytes(b []byte) string {
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,208 @@
+/*
+Copyright © 2024 Brian Greenhill <brian@briangreenhill.net>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package cmd
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/gofont/gobold"
+	"golang.org/x/image/font/gofont/goregular"
+	"golang.org/x/image/font/opentype"
+	"golang.org/x/image/math/fixed"
+)
+
+// Social cards use the size recommended for Open Graph images.
+const (
+	cardWidth   = 1200
+	cardHeight  = 630
+	cardPadding = 80
+	cardsDir    = "cards"
+)
+
+// cardPath returns the path of a post's social card, relative to the site
+// root.
+func cardPath(p post) string {
+	return assetsDirName + "/" + cardsDir + "/" + strings.TrimSuffix(p.Link, filepath.Ext(p.Link)) + ".png"
+}
+
+// generateSocialCard draws a PNG card with the post's title, author and the
+// site title and writes it to dst. Cards are cached by their content so
+// they're only drawn again when something on them changes.
+func generateSocialCard(p post, cfg *config, colors cardColors, output *outputFiles, dst string) error {
+	author := p.Author
+	if author == "" {
+		author = cfg.Author
+	}
+	meta := author
+	if p.Date != "" {
+		meta += " · " + p.Date
+	}
+
+	sum := sha256.Sum256([]byte(strings.Join([]string{
+		p.Title, meta, cfg.Title, colors.Background, colors.Foreground, colors.Accent,
+	}, "\x00")))
+	cached := filepath.Join(cacheDirName, cardsDir, hex.EncodeToString(sum[:8])+".png")
+
+	if _, err := os.Stat(cached); os.IsNotExist(err) {
+		b, err := drawSocialCard(p.Title, meta, cfg.Title, colors)
+		if err != nil {
+			return err
+		}
+		if err := os.MkdirAll(filepath.Dir(cached), 0755); err != nil {
+			return err
+		}
+		if err := writeFileAtomic(cached, b); err != nil {
+			return err
+		}
+	}
+
+	return output.copyFile(cached, dst)
+}
+
+func drawSocialCard(title, meta, siteTitle string, colors cardColors) ([]byte, error) {
+	bg, err := parseHexColor(colors.Background)
+	if err != nil {
+		return nil, fmt.Errorf("card background: %w", err)
+	}
+	fg, err := parseHexColor(colors.Foreground)
+	if err != nil {
+		return nil, fmt.Errorf("card foreground: %w", err)
+	}
+	accent, err := parseHexColor(colors.Accent)
+	if err != nil {
+		return nil, fmt.Errorf("card accent: %w", err)
+	}
+
+	img := image.NewRGBA(image.Rect(0, 0, cardWidth, cardHeight))
+	draw.Draw(img, img.Bounds(), image.NewUniform(bg), image.Point{}, draw.Src)
+	draw.Draw(img, image.Rect(0, cardHeight-16, cardWidth, cardHeight), image.NewUniform(accent), image.Point{}, draw.Src)
+
+	titleFace, err := newFace(gobold.TTF, 64)
+	if err != nil {
+		return nil, err
+	}
+	defer titleFace.Close()
+	metaFace, err := newFace(goregular.TTF, 32)
+	if err != nil {
+		return nil, err
+	}
+	defer metaFace.Close()
+	siteFace, err := newFace(gobold.TTF, 32)
+	if err != nil {
+		return nil, err
+	}
+	defer siteFace.Close()
+
+	maxWidth := fixed.I(cardWidth - 2*cardPadding)
+	y := cardPadding + 64
+	for _, line := range wrapText(titleFace, title, maxWidth, 4) {
+		drawText(img, titleFace, fg, cardPadding, y, line)
+		y += 80
+	}
+
+	drawText(img, metaFace, fg, cardPadding, y+20, meta)
+	drawText(img, siteFace, accent, cardPadding, cardHeight-cardPadding, siteTitle)
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func newFace(ttf []byte, size float64) (font.Face, error) {
+	f, err := opentype.Parse(ttf)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing font: %w", err)
+	}
+	return opentype.NewFace(f, &opentype.FaceOptions{Size: size, DPI: 72, Hinting: font.HintingFull})
+}
+
+func drawText(dst draw.Image, face font.Face, c color.Color, x, y int, s string) {
+	d := &font.Drawer{
+		Dst:  dst,
+		Src:  image.NewUniform(c),
+		Face: face,
+		Dot:  fixed.P(x, y),
+	}
+	d.DrawString(s)
+}
+
+// wrapText breaks s into lines no wider than maxWidth, ending with an
+// ellipsis if it needs more than maxLines.
+func wrapText(face font.Face, s string, maxWidth fixed.Int26_6, maxLines int) []string {
+	var lines []string
+	var line string
+	for _, word := range strings.Fields(s) {
+		candidate := word
+		if line != "" {
+			candidate = line + " " + word
+		}
+		if line != "" && font.MeasureString(face, candidate) > maxWidth {
+			lines = append(lines, line)
+			line = word
+			continue
+		}
+		line = candidate
+	}
+	if line != "" {
+		lines = append(lines, line)
+	}
+
+	if len(lines) > maxLines {
+		lines = lines[:maxLines]
+		last := lines[maxLines-1]
+		for last != "" && font.MeasureString(face, last+"…") > maxWidth {
+			last = last[:strings.LastIndex(last, " ")+1]
+			last = strings.TrimSpace(last)
+		}
+		lines[maxLines-1] = last + "…"
+	}
+	return lines
+}
+
+func parseHexColor(s string) (color.RGBA, error) {
+	s = strings.TrimPrefix(s, "#")
+	if len(s) == 3 {
+		s = string([]byte{s[0], s[0], s[1], s[1], s[2], s[2]})
+	}
+	if len(s) != 6 {
+		return color.RGBA{}, fmt.Errorf("invalid colour %q", s)
+	}
+	v, err := strconv.ParseUint(s, 16, 32)
+	if err != nil {
+		return color.RGBA{}, fmt.Errorf("invalid colour %q", s)
+	}
+	return color.RGBA{R: uint8(v >> 16), G: uint8(v >> 8), B: uint8(v), A: 255}, nil
+}
@@ -0,0 +1,147 @@
+/*
+Copyright © 2024 Brian Greenhill <brian@briangreenhill.net>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package cmd
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// loadData reads every data file under dir into a nested map keyed by the
+// file's path, so content/data/talks/2024.yaml is available to templates as
+// index .Data.talks "2024", since a key that isn't a valid identifier, such
+// as one starting with a digit, can't be used with dot syntax.
+func loadData(dir string) (map[string]any, error) {
+	data := map[string]any{}
+
+	if _, err := os.Stat(dir); os.IsNotExist(err) {
+		return data, nil
+	}
+
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() || !isDataFile(path) {
+			return nil
+		}
+
+		value, err := readDataFile(path)
+		if err != nil {
+			return fmt.Errorf("error reading data file %s: %w", path, err)
+		}
+
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		keys := strings.Split(strings.TrimSuffix(rel, filepath.Ext(rel)), string(filepath.Separator))
+
+		node := data
+		for _, key := range keys[:len(keys)-1] {
+			child, ok := node[key].(map[string]any)
+			if !ok {
+				child = map[string]any{}
+				node[key] = child
+			}
+			node = child
+		}
+
+		name := keys[len(keys)-1]
+		if _, exists := node[name]; exists {
+			return fmt.Errorf("duplicate data key %q from %s", strings.Join(keys, "."), path)
+		}
+		node[name] = value
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return data, nil
+}
+
+func isDataFile(path string) bool {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml", ".json", ".csv":
+		return true
+	default:
+		return false
+	}
+}
+
+func readDataFile(path string) (any, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var value any
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		if err := yaml.Unmarshal(b, &value); err != nil {
+			return nil, err
+		}
+	case ".json":
+		if err := json.Unmarshal(b, &value); err != nil {
+			return nil, err
+		}
+	case ".csv":
+		return readCSV(b)
+	}
+
+	return value, nil
+}
+
+// readCSV treats the first row as a header and returns one map per record,
+// so templates can range over rows and refer to columns by name.
+func readCSV(b []byte) ([]map[string]string, error) {
+	records, err := csv.NewReader(strings.NewReader(string(b))).ReadAll()
+	if err != nil {
+		return nil, err
+	}
+	if len(records) == 0 {
+		return nil, nil
+	}
+
+	header := records[0]
+	rows := make([]map[string]string, 0, len(records)-1)
+	for _, record := range records[1:] {
+		row := make(map[string]string, len(header))
+		for i, col := range header {
+			if i < len(record) {
+				row[col] = record[i]
+			}
+		}
+		rows = append(rows, row)
+	}
+
+	return rows, nil
+}
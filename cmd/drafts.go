@@ -0,0 +1,111 @@
+/*
+Copyright © 2024 Brian Greenhill <brian@briangreenhill.net>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package cmd
+
+import (
+	"html/template"
+	"net/http"
+	"sort"
+	"sync"
+)
+
+const draftsPath = "/__drafts"
+
+// unpublishedPost is a draft or future-dated post found by the last build.
+// Built is false for posts that were skipped because --drafts wasn't set.
+type unpublishedPost struct {
+	Post  post
+	Built bool
+}
+
+// unpublished holds the unpublished posts from the last build for the
+// development server's drafts page.
+var unpublished struct {
+	mu    sync.Mutex
+	posts []unpublishedPost
+}
+
+func setUnpublishedPosts(posts []unpublishedPost) {
+	sort.Slice(posts, func(i, j int) bool {
+		return posts[i].Post.Date > posts[j].Post.Date
+	})
+
+	unpublished.mu.Lock()
+	unpublished.posts = posts
+	unpublished.mu.Unlock()
+}
+
+var draftsTemplate = template.Must(template.New("drafts").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="UTF-8">
+<meta name="viewport" content="width=device-width, initial-scale=1.0">
+<title>Unpublished posts</title>
+<style>
+body { font-family: system-ui, sans-serif; max-width: 48rem; margin: 2rem auto; padding: 0 1rem; color: #333; }
+table { width: 100%; border-collapse: collapse; }
+th, td { text-align: left; padding: 0.5rem; border-bottom: 1px solid #ddd; }
+.status { font-weight: bold; color: #b45309; }
+</style>
+</head>
+<body>
+<h1>Unpublished posts</h1>
+{{ if .Posts }}
+<table>
+<tr><th>Title</th><th>Date</th><th>Status</th></tr>
+{{ range .Posts }}
+<tr>
+<td>{{ if .Built }}<a href="/posts/{{ .Post.Link }}">{{ .Post.Title }}</a>{{ else }}{{ .Post.Title }}{{ end }}</td>
+<td>{{ .Post.Date }}</td>
+<td class="status">{{ if .Post.IsDraft }}Draft{{ else }}Scheduled{{ end }}</td>
+</tr>
+{{ end }}
+</table>
+{{ if .Skipped }}<p>Unpublished posts aren't built by default. Run <code>ssg watch --drafts</code> to preview them.</p>{{ end }}
+{{ else }}
+<p>Every post is published.</p>
+{{ end }}
+</body>
+</html>
+`))
+
+// serveDrafts lists the drafts and future-dated posts from the last build.
+func serveDrafts(w http.ResponseWriter, r *http.Request) {
+	unpublished.mu.Lock()
+	posts := unpublished.posts
+	unpublished.mu.Unlock()
+
+	skipped := false
+	for _, p := range posts {
+		if !p.Built {
+			skipped = true
+		}
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if err := draftsTemplate.Execute(w, struct {
+		Posts   []unpublishedPost
+		Skipped bool
+	}{posts, skipped}); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
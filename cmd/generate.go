@@ -23,9 +23,12 @@ package cmd
 
 import (
 	"bytes"
+	"context"
+	"errors"
 	"fmt"
 	"html/template"
 	"log"
+	"net/url"
 	"os"
 	"path/filepath"
 	"regexp"
@@ -35,6 +38,7 @@ import (
 
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
+	"github.com/tdewolff/minify/v2"
 	"github.com/yuin/goldmark"
 	"github.com/yuin/goldmark/parser"
 	"github.com/yuin/goldmark/text"
@@ -44,9 +48,11 @@ import (
 const (
 	assetsDirName = "assets"
 	postsDirName  = "posts"
+	dataDirName   = "data"
 )
 
 type config struct {
+	BaseURL     string
 	Theme       string
 	ContentDir  string
 	OutputDir   string
@@ -57,6 +63,9 @@ type config struct {
 	Github      string
 	Linkedin    string
 	Email       string
+	Images      imagesConfig
+	Minify      minifyConfig
+	Search      searchConfig
 }
 
 type post struct {
@@ -67,13 +76,49 @@ type post struct {
 	CoverImg    string        `yaml:"cover_image"`
 	Date        string        `yaml:"date"`
 	Draft       bool          `yaml:"draft"`
+	Tags        []string      `yaml:"tags"`
 	Link        string        `yaml:"link"`
+	CardImg     string        `yaml:"-"`
 	Content     template.HTML `yaml:"-"`
 }
 
+// IsDraft reports whether the post is marked as a draft.
+func (p post) IsDraft() bool {
+	return p.Draft
+}
+
+// IsFuture reports whether the post is dated after today.
+func (p post) IsFuture() bool {
+	for _, layout := range []string{"2006-01-02", time.RFC3339} {
+		if date, err := time.Parse(layout, p.Date); err == nil {
+			return date.After(time.Now())
+		}
+	}
+	return false
+}
+
 type siteData struct {
-	Config *config
-	Posts  []post
+	Config    *config
+	Posts     []post
+	Data      map[string]any
+	BuildMode string
+	// Preview is true when unpublished posts are being previewed, under
+	// ssg watch or with --drafts, rather than built to publish
+	Preview bool
+}
+
+// Build modes tell templates whether they're rendering for ssg watch or for
+// publishing.
+const (
+	buildModeDevelopment = "development"
+	buildModeProduction  = "production"
+)
+
+func currentBuildMode() string {
+	if watchMode {
+		return buildModeDevelopment
+	}
+	return buildModeProduction
 }
 
 // generateCmd represents the generate command
@@ -85,28 +130,43 @@ var generateCmd = &cobra.Command{
 		if err := viper.Unmarshal(&cfg); err != nil {
 			log.Fatal("error unmarshalling config", err)
 		}
-		if err := generateSite(&cfg); err != nil {
-			log.Fatal("error generating site", err)
+		if cleanOutput {
+			if err := cleanOutputDir(&cfg); err != nil {
+				log.Fatal("error cleaning output directory: ", err)
+			}
+		}
+		if err := generateSite(cmd.Context(), &cfg); err != nil {
+			log.Fatalf("error generating site: %v", err)
 		}
 	},
 }
 
-func generateSite(cfg *config) error {
-	siteData := siteData{Config: cfg}
+// generateSite builds the site. It stops early, returning ctx.Err(), when
+// ctx is cancelled.
+func generateSite(ctx context.Context, cfg *config) error {
+	siteData := siteData{Config: cfg, BuildMode: currentBuildMode(), Preview: watchMode || includeDrafts}
 	themeDir := filepath.Join("themes", siteData.Config.Theme)
 	postsDir := filepath.Join(siteData.Config.ContentDir, postsDirName)
 	assetsDir := filepath.Join(siteData.Config.ContentDir, assetsDirName)
 	themeAssetsDir := filepath.Join(themeDir, assetsDirName)
+	dataDir := filepath.Join(siteData.Config.ContentDir, dataDirName)
 
 	if _, err := os.Stat(themeDir); os.IsNotExist(err) {
 		return fmt.Errorf("theme directory does not exist: %w", err)
 	}
 
+	if err := checkBaseURL(cfg); err != nil {
+		return err
+	}
+
+	theme, err := loadThemeConfig(themeDir)
+	if err != nil {
+		return err
+	}
+
 	requiredDirs := []string{
 		postsDir,
 		assetsDir,
-		filepath.Join(siteData.Config.OutputDir, assetsDirName),
-		filepath.Join(siteData.Config.OutputDir, postsDirName),
 	}
 
 	for _, dir := range requiredDirs {
@@ -117,11 +177,19 @@ func generateSite(cfg *config) error {
 		}
 	}
 
+	key, err := buildKey(siteData.Config, themeDir)
+	if err != nil {
+		return fmt.Errorf("error hashing build inputs: %w", err)
+	}
+	cache := loadBuildCache(key, !noCache)
+	output := newOutputFiles(siteData.Config.OutputDir, cache, memoryOutput)
+	manifest := newAssetManifest(output)
+
 	assets, err := os.ReadDir(assetsDir)
 	if err != nil {
 		return fmt.Errorf("error reading assets directory: %w", err)
 	}
-	if err := copyAssets(assetsDir, siteData.Config.OutputDir, assets); err != nil {
+	if err := copyAssets(assetsDir, output, assets, manifest); err != nil {
 		return fmt.Errorf("error copying assets: %w", err)
 	}
 
@@ -129,13 +197,39 @@ func generateSite(cfg *config) error {
 	if err != nil {
 		return fmt.Errorf("error reading theme assets directory: %w", err)
 	}
-	if err := copyAssets(themeAssetsDir, siteData.Config.OutputDir, themeAssets); err != nil {
+	if err := copyAssets(themeAssetsDir, output, themeAssets, manifest); err != nil {
 		return fmt.Errorf("error copying theme assets: %w", err)
 	}
 
-	if err := copyFile(filepath.Join(themeDir, "/style.css"), filepath.Join(siteData.Config.OutputDir, assetsDirName, "style.css")); err != nil {
+	if err := output.copyFile(filepath.Join(themeDir, "/style.css"), filepath.Join(siteData.Config.OutputDir, assetsDirName, "style.css")); err != nil {
 		return fmt.Errorf("error copying style.css: %w", err)
 	}
+	if _, err := manifest.fingerprintFile("style.css", filepath.Join(themeDir, "style.css")); err != nil {
+		return fmt.Errorf("error fingerprinting style.css: %w", err)
+	}
+
+	if err := compileStyles(themeDir, output, siteData.Config.Minify, manifest); err != nil {
+		return fmt.Errorf("error compiling styles: %w", err)
+	}
+
+	if err := buildBundles(themeDir, output, theme.Bundles, siteData.Config.Minify, manifest); err != nil {
+		return fmt.Errorf("error building bundles: %w", err)
+	}
+
+	if err := manifest.write(); err != nil {
+		return fmt.Errorf("error writing asset manifest: %w", err)
+	}
+
+	data, err := loadData(dataDir)
+	if err != nil {
+		return fmt.Errorf("error loading data files: %w", err)
+	}
+	siteData.Data = data
+
+	mdOpts, err := newMarkdownOptions(siteData.Config, themeDir, output)
+	if err != nil {
+		return err
+	}
 
 	mdFiles, err := os.ReadDir(postsDir)
 	if err != nil {
@@ -145,26 +239,51 @@ func generateSite(cfg *config) error {
 		fmt.Printf("warning: no markdown files found in %s folder\n", postsDir)
 	}
 
+	var filenames []string
 	for _, file := range mdFiles {
 		if !strings.HasSuffix(file.Name(), ".md") && !strings.HasSuffix(file.Name(), ".markdown") {
 			continue
 		}
+		filenames = append(filenames, filepath.Join(postsDir, file.Name()))
+	}
 
-		fbytes, err := os.ReadFile(filepath.Join(postsDir, file.Name()))
-		if err != nil {
-			return fmt.Errorf("error reading markdown file: %w", err)
-		}
-		p, err := parseMarkdown(fbytes)
-		if err != nil {
-			return fmt.Errorf("error parsing markdown: %w", err)
-		}
+	// posts are rendered concurrently but collected in directory order, so
+	// the output doesn't depend on which finishes first
+	posts := make([]post, len(filenames))
+	if err := ctx.Err(); err != nil {
+		return err
+	}
 
-		if p.Draft && !includeDrafts {
-			fmt.Printf("skipping draft: %s\n", p.Title)
-			continue
-		}
+	errs := parallel(ctx, len(filenames), workers, func(i int) error {
+		var err error
+		posts[i], err = loadPost(filenames[i], mdOpts, cache)
+		return err
+	})
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if err := errors.Join(errs...); err != nil {
+		return err
+	}
 
+	var unpublished []unpublishedPost
+	for _, p := range posts {
 		p.Link = fmt.Sprintf("%s-%s.html", p.Date, slugify(p.Title))
+		p.CardImg = cardPath(p)
+
+		// drafts and posts dated in the future aren't published until
+		// they're due, but --drafts builds them to preview
+		if p.IsDraft() || p.IsFuture() {
+			unpublished = append(unpublished, unpublishedPost{Post: p, Built: includeDrafts})
+			if !includeDrafts {
+				if p.IsDraft() {
+					fmt.Printf("skipping draft: %s\n", p.Title)
+				} else {
+					fmt.Printf("skipping scheduled post: %s\n", p.Title)
+				}
+				continue
+			}
+		}
 
 		siteData.Posts = append(siteData.Posts, p)
 	}
@@ -174,6 +293,22 @@ func generateSite(cfg *config) error {
 		"hasCover": func(p post) bool {
 			return p.CoverImg != ""
 		},
+		// socialImage returns the image to share a post with, preferring
+		// its cover image over the generated card
+		"socialImage": func(p post) string {
+			if p.CoverImg != "" {
+				return p.CoverImg
+			}
+			return p.CardImg
+		},
+		"absURL": func(s string) string {
+			return absURL(cfg.BaseURL, s)
+		},
+		"relURL": func(s string) string {
+			return relURL(cfg.BaseURL, s)
+		},
+		"seoData": newSEOData,
+		"asset":   manifest.lookup,
 		"sortByDate": func(posts []post) []post {
 			sort.Slice(posts, func(i, j int) bool {
 				return posts[i].Date > posts[j].Date
@@ -182,46 +317,170 @@ func generateSite(cfg *config) error {
 		},
 	}
 
+	minifier := newMinifier()
+
+	tmpl, err := loadTemplates(themeDir, funcMap)
+	if err != nil {
+		return err
+	}
+
 	// create post html files in posts directory
-	for _, p := range siteData.Posts {
-		file, err := os.Create(filepath.Join(siteData.Config.OutputDir, postsDirName, p.Link))
-		if err != nil {
-			return fmt.Errorf("error creating post file: %w", err)
+	errs = parallel(ctx, len(siteData.Posts), workers, func(i int) error {
+		p := siteData.Posts[i]
+		if err := generateSocialCard(p, siteData.Config, theme.Card, output, filepath.Join(siteData.Config.OutputDir, filepath.FromSlash(p.CardImg))); err != nil {
+			return fmt.Errorf("error generating social card for %s: %w", p.Title, err)
 		}
 
-		tmpl := template.Must(template.New("postHTML").Funcs(funcMap).ParseGlob(filepath.Join(themeDir, "*.html")))
-
-		if err := tmpl.ExecuteTemplate(file, "postHTML", struct {
-			Post   post
-			Config *config
+		var buf bytes.Buffer
+		if err := executeTemplate(tmpl, themeDir, &buf, "postHTML", struct {
+			Post      post
+			Config    *config
+			Data      map[string]any
+			BuildMode string
+			Preview   bool
 		}{
-			Post:   p,
-			Config: siteData.Config,
+			Post:      p,
+			Config:    siteData.Config,
+			Data:      siteData.Data,
+			BuildMode: siteData.BuildMode,
+			Preview:   siteData.Preview,
 		}); err != nil {
-			return fmt.Errorf("error executing template: %w", err)
+			return fmt.Errorf("error rendering %s: %w", p.Link, err)
 		}
+
+		page, err := minifyHTML(minifier, siteData.Config.Minify, buf.Bytes())
+		if err != nil {
+			return fmt.Errorf("error minifying %s: %w", p.Link, err)
+		}
+		if err := output.writeFile(filepath.Join(siteData.Config.OutputDir, postsDirName, p.Link), page); err != nil {
+			return fmt.Errorf("error writing post file: %w", err)
+		}
+		return nil
+	})
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if err := errors.Join(errs...); err != nil {
+		return err
 	}
 
-	tmpl := template.Must(template.New("baseHTML").Funcs(funcMap).ParseGlob(filepath.Join(themeDir, "*.html")))
 	// write site to output directory as index.html
+	pages := &pageWriter{tmpl: tmpl, themeDir: themeDir, minifier: minifier, minify: cfg.Minify, output: output}
+	if err := pages.write("baseHTML", "index.html", siteData); err != nil {
+		return err
+	}
 
-	file, err := os.Create(filepath.Join(siteData.Config.OutputDir, "index.html"))
-	if err != nil {
+	if tmpl.Lookup(notFoundTemplate) != nil {
+		if err := generateNotFoundPage(pages, siteData); err != nil {
+			return err
+		}
+	}
+
+	if err := writeSearchIndex(siteData, output); err != nil {
 		return err
 	}
+	if tmpl.Lookup(searchTemplate) != nil {
+		if err := pages.write(searchTemplate, searchPage, siteData); err != nil {
+			return err
+		}
+	}
 
-	if err := tmpl.ExecuteTemplate(file, "baseHTML", siteData); err != nil {
+	// a cancelled build mustn't replace the served site or remove files
+	if err := ctx.Err(); err != nil {
 		return err
 	}
 
+	if watchMode {
+		setUnpublishedPosts(unpublished)
+	}
+
+	// an output directory that's unsafe to delete from, such as the
+	// working directory, still gets generated into, just not cleaned up
+	if output.memory != nil {
+		output.publish()
+	} else if err := checkOutputDir(siteData.Config.OutputDir, siteData.Config.ContentDir, "themes"); err != nil {
+		fmt.Printf("warning: not removing stale files: %v\n", err)
+	} else if err := output.removeStale(siteData.Config); err != nil {
+		return fmt.Errorf("error removing stale files: %w", err)
+	}
+
+	if err := cache.save(); err != nil {
+		return fmt.Errorf("error saving build cache: %w", err)
+	}
+
 	return nil
 }
 
-func parseMarkdown(content []byte) (post, error) {
-	ctx := parser.NewContext()
-	md := goldmark.New(
-		goldmark.WithExtensions(&frontmatter.Extender{}),
+// loadPost reads and renders a markdown post, reusing the cached render
+// when the file hasn't changed.
+func loadPost(filename string, opts markdownOptions, cache *buildCache) (post, error) {
+	fbytes, err := os.ReadFile(filename)
+	if err != nil {
+		return post{}, fmt.Errorf("error reading markdown file: %w", err)
+	}
+
+	hash := hashBytes(fbytes)
+	if cached, ok := cache.post(filename, hash); ok {
+		// reprocess the post's images so their variants are recorded as
+		// outputs of this build; they come from the image cache
+		for _, dest := range cached.Images {
+			if _, err := opts.Images.process(dest); err != nil {
+				return post{}, err
+			}
+		}
+		return cached.Post, nil
+	}
+
+	var images []string
+	opts.ImageRefs = &images
+	p, err := parseMarkdown(filename, fbytes, opts)
+	if err != nil {
+		return post{}, fmt.Errorf("error parsing %s: %w", filename, err)
+	}
+	cache.setPost(filename, cachedPost{Hash: hash, Post: p, Images: images})
+	return p, nil
+}
+
+// markdownOptions carries the per-build state used when rendering markdown.
+// The zero value renders plain markdown with no theme extensions.
+type markdownOptions struct {
+	Shortcodes *shortcodeSet
+	Hooks      *renderHooks
+	Images     *imageProcessor
+	// ImageRefs, when set, collects the destination of every image that
+	// Images processed, so a cached render can reproduce its outputs
+	ImageRefs *[]string
+}
+
+func newMarkdownOptions(cfg *config, themeDir string, output *outputFiles) (markdownOptions, error) {
+	shortcodes, err := loadShortcodes(filepath.Join(themeDir, shortcodesDirName), cfg.ContentDir)
+	if err != nil {
+		return markdownOptions{}, fmt.Errorf("error loading shortcodes: %w", err)
+	}
+
+	hooks, err := loadRenderHooks(filepath.Join(themeDir, hooksDirName))
+	if err != nil {
+		return markdownOptions{}, fmt.Errorf("error loading render hooks: %w", err)
+	}
+
+	return markdownOptions{Shortcodes: shortcodes, Hooks: hooks, Images: newImageProcessor(cfg, output)}, nil
+}
+
+func newMarkdown(opts markdownOptions) goldmark.Markdown {
+	return goldmark.New(
+		goldmark.WithExtensions(
+			&frontmatter.Extender{},
+			&admonitionExtension{},
+			&mathExtension{},
+			&renderHooksExtension{hooks: opts.Hooks, images: opts.Images, imageRefs: opts.ImageRefs},
+		),
+		goldmark.WithParserOptions(parser.WithAutoHeadingID()),
 	)
+}
+
+func parseMarkdown(filename string, content []byte, opts markdownOptions) (post, error) {
+	ctx := parser.NewContext()
+	md := newMarkdown(opts)
 	md.Parser().Parse(text.NewReader(content), parser.WithContext(ctx))
 
 	d := frontmatter.Get(ctx)
@@ -234,16 +493,13 @@ func parseMarkdown(content []byte) (post, error) {
 		return post{}, err
 	}
 
-	// render markdown in safe mode (strips raw HTML)
-	var buf bytes.Buffer
-	if err := md.Convert(content, &buf); err != nil {
+	rendered, err := renderMarkdown(filename, 1, content, opts)
+	if err != nil {
 		return post{}, err
 	}
 
 	// extract @agent-context comment blocks from the raw source
 	// and append them to the rendered output
-	rendered := buf.String()
-	rendered = strings.ReplaceAll(rendered, "<!-- raw HTML omitted -->", "")
 	agentBlocks := extractAgentContext(content)
 	if agentBlocks != "" {
 		rendered += "\n" + agentBlocks
@@ -254,6 +510,41 @@ func parseMarkdown(content []byte) (post, error) {
 	return p, nil
 }
 
+// renderMarkdown converts markdown to HTML, expanding shortcodes. firstLine
+// is the line of content[0] within filename and is used in error messages.
+func renderMarkdown(filename string, firstLine int, content []byte, opts markdownOptions) (string, error) {
+	src := newSourceMap(content, firstLine)
+	var shortcodes map[string]template.HTML
+	if opts.Shortcodes != nil {
+		var err error
+		src, shortcodes, err = opts.Shortcodes.expand(filename, firstLine, content, opts)
+		if err != nil {
+			return "", err
+		}
+	}
+
+	// render markdown in safe mode (strips raw HTML)
+	var buf bytes.Buffer
+	ctx := parser.NewContext()
+	if err := newMarkdown(opts).Convert(src.content, &buf, parser.WithContext(ctx)); err != nil {
+		return "", fmt.Errorf("%s: %w", filename, err)
+	}
+
+	var errs []error
+	for _, e := range mathErrors(ctx) {
+		errs = append(errs, fmt.Errorf("%s:%d: invalid LaTeX: %w", filename, src.line(e.offset), e.err))
+	}
+	if len(errs) > 0 {
+		return "", errors.Join(errs...)
+	}
+
+	rendered := buf.String()
+	rendered = strings.ReplaceAll(rendered, "<!-- raw HTML omitted -->", "")
+	rendered = restoreShortcodes(rendered, shortcodes)
+
+	return rendered, nil
+}
+
 // agentContextRe matches <!-- @agent-context ... --> comment blocks
 var agentContextRe = regexp.MustCompile(`(?s)<!--\s*@agent-context\b.*?-->`)
 
@@ -271,52 +562,109 @@ func extractAgentContext(content []byte) string {
 	return strings.Join(parts, "\n")
 }
 
-func copyAssets(assetDir, outputDir string, assets []os.DirEntry) error {
+// copyAssets copies assets to the output assets directory under their own
+// names, so content can link to them directly, and under fingerprinted
+// names recorded in manifest.
+func copyAssets(assetDir string, output *outputFiles, assets []os.DirEntry, manifest *assetManifest) error {
 	for _, asset := range assets {
 		if asset.IsDir() {
 			continue
 		}
 
 		fmt.Println("copying asset: ", asset.Name())
-		if err := copyFile(filepath.Join(assetDir, asset.Name()), filepath.Join(outputDir, assetsDirName, asset.Name())); err != nil {
+		if err := output.copyFile(filepath.Join(assetDir, asset.Name()), filepath.Join(output.dir, assetsDirName, asset.Name())); err != nil {
 			return fmt.Errorf("error copying asset: %w", err)
 		}
 
+		if _, err := manifest.fingerprintFile(asset.Name(), filepath.Join(assetDir, asset.Name())); err != nil {
+			return fmt.Errorf("error fingerprinting asset: %w", err)
+		}
 	}
 	return nil
 }
 
-func copyFile(src, dst string) error {
-	// check if file to copy exists
-	if _, err := os.Stat(src); os.IsNotExist(err) {
-		return fmt.Errorf("file to copy does not exist: %w", err)
+// absURL resolves a path relative to the site root, or to a post page,
+// against baseURL. URLs that are already absolute are returned unchanged.
+func absURL(baseURL, s string) string {
+	if isExternalURL(s) {
+		return s
 	}
+	for _, prefix := range []string{"../", "./", "/"} {
+		for strings.HasPrefix(s, prefix) {
+			s = strings.TrimPrefix(s, prefix)
+		}
+	}
+	return strings.TrimSuffix(baseURL, "/") + "/" + s
+}
 
-	r, err := os.Open(src)
+// relURL is like absURL but returns a path from the root of the host, so
+// links work wherever the page is served from, including the dev server.
+func relURL(baseURL, s string) string {
+	if isExternalURL(s) {
+		return s
+	}
+	u, err := url.Parse(absURL(baseURL, s))
 	if err != nil {
-		return fmt.Errorf("error opening file to copy: %w", err)
+		return s
 	}
-	defer r.Close()
+	return u.RequestURI()
+}
 
-	// if the destination file already exists, remove it
-	if _, err := os.Stat(dst); err == nil {
-		if err := os.Remove(dst); err != nil {
-			return fmt.Errorf("error removing destination file: %w", err)
-		}
+// notFoundTemplate is the optional theme template rendered to 404.html.
+const notFoundTemplate = "404HTML"
+
+// recentPostsCount is how many posts the 404 page suggests.
+const recentPostsCount = 5
+
+// generateNotFoundPage renders the theme's 404 page with the site's most
+// recent posts, for static hosts to serve when a page doesn't exist.
+func generateNotFoundPage(pages *pageWriter, siteData siteData) error {
+	recent := append([]post(nil), siteData.Posts...)
+	sort.Slice(recent, func(i, j int) bool {
+		return recent[i].Date > recent[j].Date
+	})
+	if len(recent) > recentPostsCount {
+		recent = recent[:recentPostsCount]
 	}
 
-	// create destination file
-	w, err := os.Create(dst)
-	if err != nil {
-		return fmt.Errorf("error creating destination file: %w", err)
-	}
-	defer w.Close()
+	return pages.write(notFoundTemplate, notFoundPage, struct {
+		Config      *config
+		RecentPosts []post
+		Data        map[string]any
+		BuildMode   string
+		Preview     bool
+	}{
+		Config:      siteData.Config,
+		RecentPosts: recent,
+		Data:        siteData.Data,
+		BuildMode:   siteData.BuildMode,
+		Preview:     siteData.Preview,
+	})
+}
 
-	if _, err := w.ReadFrom(r); err != nil {
-		return fmt.Errorf("error copying file: %w", err)
+// pageWriter renders site-wide pages, such as index.html, from the theme's
+// templates.
+type pageWriter struct {
+	tmpl     *template.Template
+	themeDir string
+	minifier *minify.M
+	minify   minifyConfig
+	output   *outputFiles
+}
+
+// write renders the named template to file, relative to the output
+// directory.
+func (w *pageWriter) write(name, file string, data any) error {
+	var buf bytes.Buffer
+	if err := executeTemplate(w.tmpl, w.themeDir, &buf, name, data); err != nil {
+		return fmt.Errorf("error rendering %s: %w", file, err)
 	}
 
-	return nil
+	page, err := minifyHTML(w.minifier, w.minify, buf.Bytes())
+	if err != nil {
+		return fmt.Errorf("error minifying %s: %w", file, err)
+	}
+	return w.output.writeFile(filepath.Join(w.output.dir, file), page)
 }
 
 var nonAlphanumeric = regexp.MustCompile(`[^a-zA-Z0-9_-]+`)
@@ -327,6 +675,9 @@ func slugify(s string) string {
 	return s
 }
 
+var cleanOutput bool
+
 func init() {
 	rootCmd.AddCommand(generateCmd)
+	generateCmd.Flags().BoolVar(&cleanOutput, "clean", false, "remove everything in the output directory before generating")
 }
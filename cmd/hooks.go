@@ -0,0 +1,268 @@
+/*
+Copyright © 2024 Brian Greenhill <brian@briangreenhill.net>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package cmd
+
+import (
+	"bytes"
+	"fmt"
+	"html/template"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/renderer"
+	"github.com/yuin/goldmark/util"
+)
+
+const hooksDirName = "hooks"
+
+// Built-in render hooks, used when a theme doesn't provide its own in
+// themes/<name>/hooks/.
+const (
+	defaultLinkHook = `<a href="{{ .Destination }}"{{ with .Title }} title="{{ . }}"{{ end }}` +
+		`{{ if .IsExternal }} rel="noopener"{{ end }}>{{ .Text }}</a>`
+	defaultImageHook = `<img src="{{ .Destination }}" alt="{{ .Alt }}"{{ with .Title }} title="{{ . }}"{{ end }}` +
+		`{{ if .Width }} width="{{ .Width }}" height="{{ .Height }}"{{ end }}` +
+		`{{ with .Srcset }} srcset="{{ . }}"{{ with $.Sizes }} sizes="{{ . }}"{{ end }}{{ end }}` +
+		` loading="lazy" decoding="async" />`
+	defaultHeadingHook = `<h{{ .Level }} id="{{ .ID }}">{{ .Text }}` +
+		` <a class="heading-anchor" href="#{{ .ID }}" aria-label="Permalink to {{ .PlainText }}">#</a></h{{ .Level }}>` + "\n"
+)
+
+// renderHooks holds the templates used to render links, images and
+// headings in markdown content.
+type renderHooks struct {
+	Link    *template.Template
+	Image   *template.Template
+	Heading *template.Template
+}
+
+var defaultRenderHooks = &renderHooks{
+	Link:    template.Must(template.New("link").Parse(defaultLinkHook)),
+	Image:   template.Must(template.New("image").Parse(defaultImageHook)),
+	Heading: template.Must(template.New("heading").Parse(defaultHeadingHook)),
+}
+
+// linkHook is the data passed to the link render hook.
+type linkHook struct {
+	Destination string
+	Title       string
+	Text        template.HTML
+	PlainText   string
+	IsExternal  bool
+}
+
+// imageHook is the data passed to the image render hook. Width, Height,
+// Srcset and Sizes are only set for images processed from content/assets.
+type imageHook struct {
+	Destination string
+	Title       string
+	Alt         string
+	IsExternal  bool
+	Width       int
+	Height      int
+	Srcset      string
+	Sizes       string
+}
+
+// headingHook is the data passed to the heading render hook.
+type headingHook struct {
+	Level     int
+	ID        string
+	Text      template.HTML
+	PlainText string
+}
+
+// loadRenderHooks reads link.html, image.html and heading.html from dir,
+// falling back to the built-in hooks for any that are missing.
+func loadRenderHooks(dir string) (*renderHooks, error) {
+	hooks := *defaultRenderHooks
+	for name, hook := range map[string]**template.Template{
+		"link":    &hooks.Link,
+		"image":   &hooks.Image,
+		"heading": &hooks.Heading,
+	} {
+		file := filepath.Join(dir, name+".html")
+		b, err := os.ReadFile(file)
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("error reading render hook %s: %w", file, err)
+		}
+		tmpl, err := template.New(name).Parse(string(b))
+		if err != nil {
+			return nil, fmt.Errorf("error parsing render hook %s: %w", file, err)
+		}
+		*hook = tmpl
+	}
+	return &hooks, nil
+}
+
+// renderHooksExtension renders links, images and headings through the
+// render hook templates.
+type renderHooksExtension struct {
+	hooks     *renderHooks
+	images    *imageProcessor
+	imageRefs *[]string
+}
+
+func (e *renderHooksExtension) Extend(m goldmark.Markdown) {
+	hooks := e.hooks
+	if hooks == nil {
+		hooks = defaultRenderHooks
+	}
+	m.Renderer().AddOptions(
+		renderer.WithNodeRenderers(util.Prioritized(&hookRenderer{hooks: hooks, images: e.images, imageRefs: e.imageRefs, md: m}, 100)),
+	)
+}
+
+type hookRenderer struct {
+	hooks     *renderHooks
+	images    *imageProcessor
+	imageRefs *[]string
+	md        goldmark.Markdown
+}
+
+func (r *hookRenderer) RegisterFuncs(reg renderer.NodeRendererFuncRegisterer) {
+	reg.Register(ast.KindLink, r.renderLink)
+	reg.Register(ast.KindAutoLink, r.renderAutoLink)
+	reg.Register(ast.KindImage, r.renderImage)
+	reg.Register(ast.KindHeading, r.renderHeading)
+}
+
+func (r *hookRenderer) renderLink(w util.BufWriter, source []byte, node ast.Node, entering bool) (ast.WalkStatus, error) {
+	if !entering {
+		return ast.WalkContinue, nil
+	}
+	n := node.(*ast.Link)
+	text, err := r.renderChildren(source, n)
+	if err != nil {
+		return ast.WalkStop, err
+	}
+	dest := string(n.Destination)
+	return ast.WalkSkipChildren, r.execute(w, r.hooks.Link, linkHook{
+		Destination: dest,
+		Title:       string(n.Title),
+		Text:        text,
+		PlainText:   string(n.Text(source)),
+		IsExternal:  isExternalURL(dest),
+	})
+}
+
+// renderAutoLink renders <https://...> and <user@example.com> autolinks
+// through the link hook, like other links.
+func (r *hookRenderer) renderAutoLink(w util.BufWriter, source []byte, node ast.Node, entering bool) (ast.WalkStatus, error) {
+	if !entering {
+		return ast.WalkContinue, nil
+	}
+	n := node.(*ast.AutoLink)
+	dest := string(n.URL(source))
+	if n.AutoLinkType == ast.AutoLinkEmail && !strings.HasPrefix(strings.ToLower(dest), "mailto:") {
+		dest = "mailto:" + dest
+	}
+	label := string(n.Label(source))
+	return ast.WalkSkipChildren, r.execute(w, r.hooks.Link, linkHook{
+		Destination: dest,
+		Text:        template.HTML(template.HTMLEscapeString(label)),
+		PlainText:   label,
+		IsExternal:  isExternalURL(dest),
+	})
+}
+
+func (r *hookRenderer) renderImage(w util.BufWriter, source []byte, node ast.Node, entering bool) (ast.WalkStatus, error) {
+	if !entering {
+		return ast.WalkContinue, nil
+	}
+	n := node.(*ast.Image)
+	dest := string(n.Destination)
+	data := imageHook{
+		Destination: dest,
+		Title:       string(n.Title),
+		Alt:         string(n.Text(source)),
+		IsExternal:  isExternalURL(dest),
+	}
+	if r.images != nil {
+		img, err := r.images.process(dest)
+		if err != nil {
+			return ast.WalkStop, err
+		}
+		if img != nil {
+			data.Width = img.Width
+			data.Height = img.Height
+			data.Srcset = img.srcset(dest)
+			data.Sizes = r.images.config.Sizes
+			if r.imageRefs != nil {
+				*r.imageRefs = append(*r.imageRefs, dest)
+			}
+		}
+	}
+	return ast.WalkSkipChildren, r.execute(w, r.hooks.Image, data)
+}
+
+func (r *hookRenderer) renderHeading(w util.BufWriter, source []byte, node ast.Node, entering bool) (ast.WalkStatus, error) {
+	if !entering {
+		return ast.WalkContinue, nil
+	}
+	n := node.(*ast.Heading)
+	text, err := r.renderChildren(source, n)
+	if err != nil {
+		return ast.WalkStop, err
+	}
+	var id string
+	if v, ok := n.AttributeString("id"); ok {
+		if b, ok := v.([]byte); ok {
+			id = string(b)
+		}
+	}
+	return ast.WalkSkipChildren, r.execute(w, r.hooks.Heading, headingHook{
+		Level:     n.Level,
+		ID:        id,
+		Text:      text,
+		PlainText: string(n.Text(source)),
+	})
+}
+
+// renderChildren renders the children of n with the full markdown renderer,
+// so hooks receive formatted text such as emphasis and code.
+func (r *hookRenderer) renderChildren(source []byte, n ast.Node) (template.HTML, error) {
+	var buf bytes.Buffer
+	for c := n.FirstChild(); c != nil; c = c.NextSibling() {
+		if err := r.md.Renderer().Render(&buf, source, c); err != nil {
+			return "", err
+		}
+	}
+	return template.HTML(buf.String()), nil
+}
+
+func (r *hookRenderer) execute(w util.BufWriter, tmpl *template.Template, data any) error {
+	if err := tmpl.Execute(w, data); err != nil {
+		return fmt.Errorf("error executing %s render hook: %w", tmpl.Name(), err)
+	}
+	return nil
+}
+
+func isExternalURL(dest string) bool {
+	return strings.HasPrefix(dest, "http://") || strings.HasPrefix(dest, "https://") || strings.HasPrefix(dest, "//")
+}
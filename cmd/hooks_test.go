@@ -0,0 +1,72 @@
+/*
+Copyright © 2024 Brian Greenhill <brian@briangreenhill.net>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package cmd
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/yuin/goldmark"
+)
+
+func TestLinkHook(t *testing.T) {
+	tests := []struct {
+		name string
+		src  string
+		want string
+	}{
+		{
+			name: "internal link",
+			src:  "[about](about.html)",
+			want: `<p><a href="about.html">about</a></p>`,
+		},
+		{
+			name: "external link",
+			src:  `[site](https://example.com "Example")`,
+			want: `<p><a href="https://example.com" title="Example" rel="noopener">site</a></p>`,
+		},
+		{
+			name: "autolink",
+			src:  "<https://example.com/a?b=1&c=2>",
+			want: `<p><a href="https://example.com/a?b=1&amp;c=2" rel="noopener">https://example.com/a?b=1&amp;c=2</a></p>`,
+		},
+		{
+			name: "email autolink",
+			src:  "<me@example.com>",
+			want: `<p><a href="mailto:me@example.com">me@example.com</a></p>`,
+		},
+	}
+
+	md := goldmark.New(goldmark.WithExtensions(&renderHooksExtension{}))
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			if err := md.Convert([]byte(tt.src), &buf); err != nil {
+				t.Fatalf("Convert(%q) error = %v", tt.src, err)
+			}
+			if got := strings.TrimSpace(buf.String()); got != tt.want {
+				t.Errorf("Convert(%q) = %q, want %q", tt.src, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,261 @@
+/*
+Copyright © 2024 Brian Greenhill <brian@briangreenhill.net>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package cmd
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"image"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"golang.org/x/image/draw"
+)
+
+const cacheDirName = ".ssg-cache"
+
+type imagesConfig struct {
+	Widths  []int
+	Sizes   string
+	Quality int
+}
+
+// processedImage describes an image and the resized variants generated for
+// it. Srcset paths are relative to the directory of the original.
+type processedImage struct {
+	Width    int
+	Height   int
+	Variants []imageVariant
+}
+
+type imageVariant struct {
+	Name  string
+	Width int
+}
+
+// imageProcessor resizes images from the content assets directory into the
+// configured widths, keeping encoded variants in a cache directory so that
+// unchanged images aren't resized again on the next build.
+type imageProcessor struct {
+	assetsDir string
+	outputDir string
+	cacheDir  string
+	output    *outputFiles
+	config    imagesConfig
+
+	mu   sync.Mutex
+	seen map[string]*imageJob
+}
+
+// imageJob processes one image once, however many posts use it at the same
+// time.
+type imageJob struct {
+	once sync.Once
+	img  *processedImage
+	err  error
+}
+
+func newImageProcessor(cfg *config, output *outputFiles) *imageProcessor {
+	images := cfg.Images
+	if images.Quality <= 0 || images.Quality > 100 {
+		images.Quality = jpeg.DefaultQuality
+	}
+	sort.Ints(images.Widths)
+
+	return &imageProcessor{
+		assetsDir: filepath.Join(cfg.ContentDir, assetsDirName),
+		outputDir: filepath.Join(cfg.OutputDir, assetsDirName),
+		cacheDir:  filepath.Join(cacheDirName, "images"),
+		output:    output,
+		config:    images,
+		seen:      map[string]*imageJob{},
+	}
+}
+
+// assetName returns the name of the content asset that dest refers to, or
+// "" if dest isn't a processable image in the assets directory.
+func (p *imageProcessor) assetName(dest string) string {
+	if isExternalURL(dest) || strings.Contains(dest, ":") {
+		return ""
+	}
+	name := strings.TrimLeft(path.Clean("/"+strings.TrimLeft(dest, "./")), "/")
+	name, ok := strings.CutPrefix(name, assetsDirName+"/")
+	if !ok {
+		return ""
+	}
+	switch strings.ToLower(path.Ext(name)) {
+	case ".jpg", ".jpeg", ".png", ".gif":
+		return name
+	default:
+		return ""
+	}
+}
+
+// process returns the dimensions and variants of the image referenced by
+// dest, generating the variants in the output directory. It returns nil if
+// dest isn't an image in the content assets directory.
+func (p *imageProcessor) process(dest string) (*processedImage, error) {
+	name := p.assetName(dest)
+	if name == "" {
+		return nil, nil
+	}
+
+	// only the lookup is locked, so different images are processed in
+	// parallel while posts sharing an image wait for the same job
+	p.mu.Lock()
+	job, ok := p.seen[name]
+	if !ok {
+		job = &imageJob{}
+		p.seen[name] = job
+	}
+	p.mu.Unlock()
+
+	job.once.Do(func() {
+		job.img, job.err = p.processImage(name)
+	})
+	return job.img, job.err
+}
+
+// processImage reads the named content asset and generates its variants.
+func (p *imageProcessor) processImage(name string) (*processedImage, error) {
+	src := filepath.Join(p.assetsDir, filepath.FromSlash(name))
+	b, err := os.ReadFile(src)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error reading image %s: %w", src, err)
+	}
+
+	cfg, format, err := image.DecodeConfig(bytes.NewReader(b))
+	if err != nil {
+		return nil, fmt.Errorf("error decoding image %s: %w", src, err)
+	}
+
+	img := &processedImage{Width: cfg.Width, Height: cfg.Height}
+
+	var decoded image.Image
+	for _, width := range p.config.Widths {
+		if width >= cfg.Width {
+			break
+		}
+		if format == "gif" && isAnimatedGIF(b) {
+			// resizing would drop every frame but the first
+			break
+		}
+
+		variant := variantName(name, width)
+		sum := sha256.Sum256(append(b, []byte(fmt.Sprintf("%d:%d", width, p.config.Quality))...))
+		cached := filepath.Join(p.cacheDir, hex.EncodeToString(sum[:8])+"-"+path.Base(variant))
+
+		if _, err := os.Stat(cached); os.IsNotExist(err) {
+			if decoded == nil {
+				if decoded, _, err = image.Decode(bytes.NewReader(b)); err != nil {
+					return nil, fmt.Errorf("error decoding image %s: %w", src, err)
+				}
+			}
+			fmt.Printf("resizing image: %s to %dpx\n", name, width)
+			if err := p.resize(decoded, format, width, cached); err != nil {
+				return nil, fmt.Errorf("error resizing image %s: %w", src, err)
+			}
+		}
+
+		dst := filepath.Join(p.outputDir, filepath.FromSlash(variant))
+		if err := p.output.copyFile(cached, dst); err != nil {
+			return nil, fmt.Errorf("error copying image variant: %w", err)
+		}
+
+		img.Variants = append(img.Variants, imageVariant{Name: path.Base(variant), Width: width})
+	}
+
+	return img, nil
+}
+
+func (p *imageProcessor) resize(src image.Image, format string, width int, dst string) error {
+	bounds := src.Bounds()
+	height := bounds.Dy() * width / bounds.Dx()
+	if height < 1 {
+		height = 1
+	}
+	resized := image.NewRGBA(image.Rect(0, 0, width, height))
+	draw.CatmullRom.Scale(resized, resized.Bounds(), src, bounds, draw.Over, nil)
+
+	var buf bytes.Buffer
+	switch format {
+	case "jpeg":
+		if err := jpeg.Encode(&buf, resized, &jpeg.Options{Quality: p.config.Quality}); err != nil {
+			return err
+		}
+	case "png":
+		if err := png.Encode(&buf, resized); err != nil {
+			return err
+		}
+	case "gif":
+		if err := gif.Encode(&buf, resized, nil); err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf("unsupported image format %s", format)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+		return err
+	}
+	return writeFileAtomic(dst, buf.Bytes())
+}
+
+// srcset returns the srcset attribute value for img, using dest as the
+// path to the original.
+func (img *processedImage) srcset(dest string) string {
+	if len(img.Variants) == 0 {
+		return ""
+	}
+	dir := dest[:strings.LastIndex(dest, "/")+1]
+	var parts []string
+	for _, v := range img.Variants {
+		parts = append(parts, dir+v.Name+" "+strconv.Itoa(v.Width)+"w")
+	}
+	parts = append(parts, dest+" "+strconv.Itoa(img.Width)+"w")
+	return strings.Join(parts, ", ")
+}
+
+// variantName returns the asset name of the width variant of name, such as
+// photos/cat-480w.jpg for photos/cat.jpg.
+func variantName(name string, width int) string {
+	ext := path.Ext(name)
+	return fmt.Sprintf("%s-%dw%s", strings.TrimSuffix(name, ext), width, ext)
+}
+
+func isAnimatedGIF(b []byte) bool {
+	g, err := gif.DecodeAll(bytes.NewReader(b))
+	return err == nil && len(g.Image) > 1
+}
@@ -0,0 +1,94 @@
+/*
+Copyright © 2024 Brian Greenhill <brian@briangreenhill.net>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package cmd
+
+import (
+	"image"
+	"image/png"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+)
+
+func writeTestPNG(t *testing.T, path string, width, height int) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if err := png.Encode(f, image.NewRGBA(image.Rect(0, 0, width, height))); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestImageProcessorConcurrent(t *testing.T) {
+	dir := t.TempDir()
+	cfg := &config{
+		ContentDir: filepath.Join(dir, "content"),
+		OutputDir:  filepath.Join(dir, "public"),
+		Images:     imagesConfig{Widths: []int{50, 100}},
+	}
+	writeTestPNG(t, filepath.Join(cfg.ContentDir, assetsDirName, "a.png"), 200, 100)
+	writeTestPNG(t, filepath.Join(cfg.ContentDir, assetsDirName, "b.png"), 80, 40)
+
+	p := newImageProcessor(cfg, newOutputFiles(cfg.OutputDir, nil, newMemoryFS()))
+	p.cacheDir = filepath.Join(dir, "cache")
+
+	dests := []string{"/assets/a.png", "/assets/b.png"}
+	results := make([]*processedImage, 8*len(dests))
+	var wg sync.WaitGroup
+	for i := range results {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			img, err := p.process(dests[i%len(dests)])
+			if err != nil {
+				t.Errorf("process(%s) error = %v", dests[i%len(dests)], err)
+			}
+			results[i] = img
+		}(i)
+	}
+	wg.Wait()
+
+	for i, img := range results {
+		if img != results[i%len(dests)] {
+			t.Fatalf("process(%s) returned different results for the same image", dests[i%len(dests)])
+		}
+	}
+
+	a, b := results[0], results[1]
+	if a.Width != 200 || a.Height != 100 || len(a.Variants) != 2 {
+		t.Errorf("a.png = %dx%d with %d variants, want 200x100 with 2", a.Width, a.Height, len(a.Variants))
+	}
+	if b.Width != 80 || len(b.Variants) != 1 {
+		t.Errorf("b.png = %dpx wide with %d variants, want 80px with 1", b.Width, len(b.Variants))
+	}
+
+	if img, err := p.process("/assets/missing.png"); img != nil || err != nil {
+		t.Errorf("process(missing.png) = %v, %v, want nil, nil", img, err)
+	}
+}
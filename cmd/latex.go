@@ -0,0 +1,715 @@
+/*
+Copyright © 2024 Brian Greenhill <brian@briangreenhill.net>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package cmd
+
+import (
+	"fmt"
+	"html"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// latexToMathML converts a LaTeX math expression to presentation MathML so
+// equations render without client-side JavaScript. It understands the
+// commonly used subset of LaTeX math: scripts, fractions, roots, greek
+// letters, operators, fonts, accents, \left/\right and matrix-like
+// environments.
+func latexToMathML(src string, display bool) (string, error) {
+	p := &latexParser{src: src}
+	nodes, err := p.parseList(func() bool { return false })
+	if err != nil {
+		return "", err
+	}
+	if p.pos < len(p.src) {
+		return "", fmt.Errorf("unexpected %q at offset %d", p.src[p.pos:p.pos+1], p.pos)
+	}
+
+	var b strings.Builder
+	b.WriteString(`<math xmlns="http://www.w3.org/1998/Math/MathML"`)
+	if display {
+		b.WriteString(` display="block"`)
+	}
+	b.WriteString(`><semantics>`)
+	b.WriteString(mrow(nodes))
+	b.WriteString(`<annotation encoding="application/x-tex">`)
+	b.WriteString(html.EscapeString(strings.TrimSpace(src)))
+	b.WriteString(`</annotation></semantics></math>`)
+
+	return b.String(), nil
+}
+
+type latexParser struct {
+	src  string
+	pos  int
+	font string
+}
+
+func (p *latexParser) eof() bool {
+	return p.pos >= len(p.src)
+}
+
+func (p *latexParser) skipSpace() {
+	for !p.eof() && unicode.IsSpace(rune(p.src[p.pos])) {
+		p.pos++
+	}
+}
+
+// peekCommand returns the name of the command at the current position
+// without consuming it, or "" if there isn't one.
+func (p *latexParser) peekCommand() string {
+	if p.eof() || p.src[p.pos] != '\\' || p.pos+1 >= len(p.src) {
+		return ""
+	}
+	rest := p.src[p.pos+1:]
+	if !isASCIILetter(rest[0]) {
+		return rest[:1]
+	}
+	n := 0
+	for n < len(rest) && isASCIILetter(rest[n]) {
+		n++
+	}
+	return rest[:n]
+}
+
+func (p *latexParser) readCommand() string {
+	name := p.peekCommand()
+	p.pos += 1 + len(name)
+	return name
+}
+
+// parseList parses atoms until the input ends, a closing brace is found or
+// stop reports true. The terminator is not consumed.
+func (p *latexParser) parseList(stop func() bool) ([]string, error) {
+	var nodes []string
+	for {
+		p.skipSpace()
+		if p.eof() || p.src[p.pos] == '}' || stop() {
+			return nodes, nil
+		}
+		node, err := p.parseScripted()
+		if err != nil {
+			return nil, err
+		}
+		if node != "" {
+			nodes = append(nodes, node)
+		}
+	}
+}
+
+// parseScripted parses an atom followed by any sub/superscripts and primes.
+func (p *latexParser) parseScripted() (string, error) {
+	base, limits, err := p.parseAtom()
+	if err != nil {
+		return "", err
+	}
+
+	var sub, sup string
+	for {
+		p.skipSpace()
+		if p.eof() {
+			break
+		}
+		switch c := p.src[p.pos]; c {
+		case '^', '_':
+			p.pos++
+			arg, err := p.parseArg()
+			if err != nil {
+				return "", err
+			}
+			if c == '^' {
+				if sup != "" {
+					return "", fmt.Errorf("double superscript")
+				}
+				sup = arg
+			} else {
+				if sub != "" {
+					return "", fmt.Errorf("double subscript")
+				}
+				sub = arg
+			}
+			continue
+		case '\'':
+			primes := ""
+			for !p.eof() && p.src[p.pos] == '\'' {
+				primes += "′"
+				p.pos++
+			}
+			sup += "<mo>" + primes + "</mo>"
+			continue
+		}
+		break
+	}
+
+	if base == "" && (sub != "" || sup != "") {
+		base = "<mrow></mrow>"
+	}
+
+	under, over := "msub", "msup"
+	both := "msubsup"
+	if limits {
+		under, over, both = "munder", "mover", "munderover"
+	}
+	switch {
+	case sub != "" && sup != "":
+		return "<" + both + ">" + base + sub + sup + "</" + both + ">", nil
+	case sub != "":
+		return "<" + under + ">" + base + sub + "</" + under + ">", nil
+	case sup != "":
+		return "<" + over + ">" + base + sup + "</" + over + ">", nil
+	}
+	return base, nil
+}
+
+// parseArg parses a single command or script argument: a braced group or
+// one token.
+func (p *latexParser) parseArg() (string, error) {
+	p.skipSpace()
+	if p.eof() {
+		return "", fmt.Errorf("missing argument")
+	}
+	if p.src[p.pos] == '{' {
+		return p.parseGroup()
+	}
+	if c := p.src[p.pos]; c >= '0' && c <= '9' {
+		p.pos++
+		return "<mn>" + p.styled(string(c)) + "</mn>", nil
+	}
+	node, _, err := p.parseAtom()
+	return node, err
+}
+
+func (p *latexParser) parseGroup() (string, error) {
+	p.pos++ // {
+	nodes, err := p.parseList(func() bool { return false })
+	if err != nil {
+		return "", err
+	}
+	if p.eof() {
+		return "", fmt.Errorf("missing closing }")
+	}
+	p.pos++ // }
+	return mrow(nodes), nil
+}
+
+// readRawGroup returns the unparsed contents of a braced group, as used by
+// \text and \operatorname.
+func (p *latexParser) readRawGroup() (string, error) {
+	p.skipSpace()
+	if p.eof() || p.src[p.pos] != '{' {
+		return "", fmt.Errorf("expected {")
+	}
+	depth := 0
+	for i := p.pos; i < len(p.src); i++ {
+		switch p.src[i] {
+		case '\\':
+			i++
+		case '{':
+			depth++
+		case '}':
+			depth--
+			if depth == 0 {
+				raw := p.src[p.pos+1 : i]
+				p.pos = i + 1
+				return raw, nil
+			}
+		}
+	}
+	return "", fmt.Errorf("missing closing }")
+}
+
+// parseAtom parses a single element. limits reports whether scripts on it
+// should be placed under and over rather than to the side.
+func (p *latexParser) parseAtom() (node string, limits bool, err error) {
+	c := p.src[p.pos]
+	switch {
+	case c == '{':
+		node, err = p.parseGroup()
+		return node, false, err
+	case c == '\\':
+		return p.parseCommand()
+	case c == '^' || c == '_':
+		return "", false, nil
+	case c == '&':
+		return "", false, fmt.Errorf("unexpected & outside of an environment")
+	case c >= '0' && c <= '9' || c == '.' && p.pos+1 < len(p.src) && isDigit(p.src[p.pos+1]):
+		start := p.pos
+		for !p.eof() && (isDigit(p.src[p.pos]) || p.src[p.pos] == '.') {
+			p.pos++
+		}
+		return "<mn>" + p.styled(p.src[start:p.pos]) + "</mn>", false, nil
+	case isASCIILetter(c):
+		p.pos++
+		return p.identifier(string(c)), false, nil
+	case c == '~':
+		p.pos++
+		return `<mspace width="0.3333em"></mspace>`, false, nil
+	}
+
+	r, size := utf8.DecodeRuneInString(p.src[p.pos:])
+	p.pos += size
+	switch r {
+	case '-':
+		return "<mo>−</mo>", false, nil
+	case '*':
+		return "<mo>∗</mo>", false, nil
+	case '\'':
+		return "<mo>′</mo>", false, nil
+	}
+	if unicode.IsLetter(r) {
+		return p.identifier(string(r)), false, nil
+	}
+	return "<mo>" + html.EscapeString(string(r)) + "</mo>", false, nil
+}
+
+func (p *latexParser) identifier(s string) string {
+	if p.font == "rm" {
+		return `<mi mathvariant="normal">` + html.EscapeString(s) + "</mi>"
+	}
+	return "<mi>" + html.EscapeString(p.styled(s)) + "</mi>"
+}
+
+// styled maps letters and digits to the Unicode mathematical alphanumeric
+// symbols for the current font, since MathML Core only supports
+// mathvariant="normal".
+func (p *latexParser) styled(s string) string {
+	font, ok := mathFonts[p.font]
+	if !ok {
+		return s
+	}
+	var b strings.Builder
+	for _, r := range s {
+		if special, ok := font.special[r]; ok {
+			b.WriteRune(special)
+			continue
+		}
+		switch {
+		case r >= 'A' && r <= 'Z' && font.upper != 0:
+			b.WriteRune(font.upper + r - 'A')
+		case r >= 'a' && r <= 'z' && font.lower != 0:
+			b.WriteRune(font.lower + r - 'a')
+		case r >= '0' && r <= '9' && font.digit != 0:
+			b.WriteRune(font.digit + r - '0')
+		default:
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+func (p *latexParser) parseCommand() (string, bool, error) {
+	name := p.readCommand()
+	if name == "" {
+		return "", false, fmt.Errorf("trailing backslash")
+	}
+
+	if s, ok := mathIdentifiers[name]; ok {
+		return "<mi>" + s + "</mi>", false, nil
+	}
+	if s, ok := mathOperators[name]; ok {
+		return "<mo>" + html.EscapeString(s) + "</mo>", false, nil
+	}
+	if s, ok := mathLargeOperators[name]; ok {
+		return `<mo largeop="true" movablelimits="true">` + s + "</mo>", true, nil
+	}
+	if s, ok := mathIntegrals[name]; ok {
+		return `<mo largeop="true">` + s + "</mo>", false, nil
+	}
+	if _, ok := mathFunctions[name]; ok {
+		return "<mi>" + name + "</mi><mo>⁡</mo>", false, nil
+	}
+	if _, ok := mathLimitFunctions[name]; ok {
+		return `<mo movablelimits="true" form="prefix">` + mathLimitFunctions[name] + "</mo>", true, nil
+	}
+	if width, ok := mathSpaces[name]; ok {
+		return `<mspace width="` + width + `"></mspace>`, false, nil
+	}
+	if accent, ok := mathAccents[name]; ok {
+		arg, err := p.parseArg()
+		if err != nil {
+			return "", false, fmt.Errorf("\\%s: %w", name, err)
+		}
+		return `<mover accent="true">` + arg + `<mo stretchy="false">` + accent + "</mo></mover>", false, nil
+	}
+	if _, ok := mathFonts[name[min(len(name), 4):]]; ok && strings.HasPrefix(name, "math") {
+		return p.withFont(name[4:])
+	}
+
+	switch name {
+	case "frac", "dfrac", "tfrac", "cfrac":
+		num, err := p.parseArg()
+		if err != nil {
+			return "", false, fmt.Errorf("\\%s: %w", name, err)
+		}
+		den, err := p.parseArg()
+		if err != nil {
+			return "", false, fmt.Errorf("\\%s: %w", name, err)
+		}
+		return "<mfrac>" + num + den + "</mfrac>", false, nil
+	case "binom":
+		n, err := p.parseArg()
+		if err != nil {
+			return "", false, fmt.Errorf("\\binom: %w", err)
+		}
+		k, err := p.parseArg()
+		if err != nil {
+			return "", false, fmt.Errorf("\\binom: %w", err)
+		}
+		return `<mrow><mo>(</mo><mfrac linethickness="0">` + n + k + `</mfrac><mo>)</mo></mrow>`, false, nil
+	case "sqrt":
+		p.skipSpace()
+		var index string
+		if !p.eof() && p.src[p.pos] == '[' {
+			p.pos++
+			nodes, err := p.parseList(func() bool { return p.src[p.pos] == ']' })
+			if err != nil {
+				return "", false, err
+			}
+			if p.eof() || p.src[p.pos] != ']' {
+				return "", false, fmt.Errorf("\\sqrt: missing ]")
+			}
+			p.pos++
+			index = mrow(nodes)
+		}
+		arg, err := p.parseArg()
+		if err != nil {
+			return "", false, fmt.Errorf("\\sqrt: %w", err)
+		}
+		if index != "" {
+			return "<mroot>" + arg + index + "</mroot>", false, nil
+		}
+		return "<msqrt>" + arg + "</msqrt>", false, nil
+	case "text", "textrm", "textbf", "textit", "mbox":
+		raw, err := p.readRawGroup()
+		if err != nil {
+			return "", false, fmt.Errorf("\\%s: %w", name, err)
+		}
+		return "<mtext>" + html.EscapeString(raw) + "</mtext>", false, nil
+	case "operatorname":
+		raw, err := p.readRawGroup()
+		if err != nil {
+			return "", false, fmt.Errorf("\\operatorname: %w", err)
+		}
+		return "<mi>" + html.EscapeString(raw) + "</mi><mo>⁡</mo>", false, nil
+	case "overline", "underline":
+		arg, err := p.parseArg()
+		if err != nil {
+			return "", false, fmt.Errorf("\\%s: %w", name, err)
+		}
+		if name == "overline" {
+			return `<mover accent="true">` + arg + `<mo>‾</mo></mover>`, false, nil
+		}
+		return `<munder accentunder="true">` + arg + `<mo>_</mo></munder>`, false, nil
+	case "overbrace", "underbrace":
+		arg, err := p.parseArg()
+		if err != nil {
+			return "", false, fmt.Errorf("\\%s: %w", name, err)
+		}
+		if name == "overbrace" {
+			return `<mover>` + arg + `<mo>⏞</mo></mover>`, true, nil
+		}
+		return `<munder>` + arg + `<mo>⏟</mo></munder>`, true, nil
+	case "left":
+		return p.parseLeftRight()
+	case "right":
+		return "", false, fmt.Errorf("\\right without matching \\left")
+	case "begin":
+		return p.parseEnvironment()
+	case "end":
+		return "", false, fmt.Errorf("\\end without matching \\begin")
+	}
+
+	return "", false, fmt.Errorf("unknown command \\%s", name)
+}
+
+func (p *latexParser) withFont(font string) (string, bool, error) {
+	prev := p.font
+	p.font = font
+	defer func() { p.font = prev }()
+
+	arg, err := p.parseArg()
+	if err != nil {
+		return "", false, fmt.Errorf("\\math%s: %w", font, err)
+	}
+	return arg, false, nil
+}
+
+// readDelimiter reads the delimiter following \left or \right.
+func (p *latexParser) readDelimiter() (string, error) {
+	p.skipSpace()
+	if p.eof() {
+		return "", fmt.Errorf("missing delimiter")
+	}
+	if p.src[p.pos] == '\\' {
+		name := p.readCommand()
+		if s, ok := mathOperators[name]; ok {
+			return s, nil
+		}
+		return "", fmt.Errorf("invalid delimiter \\%s", name)
+	}
+	r, size := utf8.DecodeRuneInString(p.src[p.pos:])
+	p.pos += size
+	if r == '.' {
+		return "", nil
+	}
+	return string(r), nil
+}
+
+func (p *latexParser) parseLeftRight() (string, bool, error) {
+	open, err := p.readDelimiter()
+	if err != nil {
+		return "", false, fmt.Errorf("\\left: %w", err)
+	}
+	nodes, err := p.parseList(func() bool { return p.peekCommand() == "right" })
+	if err != nil {
+		return "", false, err
+	}
+	if p.peekCommand() != "right" {
+		return "", false, fmt.Errorf("\\left without matching \\right")
+	}
+	p.readCommand()
+	closing, err := p.readDelimiter()
+	if err != nil {
+		return "", false, fmt.Errorf("\\right: %w", err)
+	}
+
+	var b strings.Builder
+	b.WriteString("<mrow>")
+	if open != "" {
+		b.WriteString(`<mo fence="true" stretchy="true">` + html.EscapeString(open) + "</mo>")
+	}
+	b.WriteString(strings.Join(nodes, ""))
+	if closing != "" {
+		b.WriteString(`<mo fence="true" stretchy="true">` + html.EscapeString(closing) + "</mo>")
+	}
+	b.WriteString("</mrow>")
+	return b.String(), false, nil
+}
+
+// mathEnvironments maps supported environments to their opening and closing
+// fences.
+var mathEnvironments = map[string][2]string{
+	"matrix":      {"", ""},
+	"smallmatrix": {"", ""},
+	"pmatrix":     {"(", ")"},
+	"bmatrix":     {"[", "]"},
+	"Bmatrix":     {"{", "}"},
+	"vmatrix":     {"|", "|"},
+	"Vmatrix":     {"‖", "‖"},
+	"cases":       {"{", ""},
+	"aligned":     {"", ""},
+	"align":       {"", ""},
+	"align*":      {"", ""},
+	"gathered":    {"", ""},
+	"array":       {"", ""},
+}
+
+func (p *latexParser) parseEnvironment() (string, bool, error) {
+	env, err := p.readRawGroup()
+	if err != nil {
+		return "", false, fmt.Errorf("\\begin: %w", err)
+	}
+	fences, ok := mathEnvironments[env]
+	if !ok {
+		return "", false, fmt.Errorf("unknown environment %q", env)
+	}
+	if env == "array" {
+		// column specs only affect alignment, which we leave to the browser
+		if _, err := p.readRawGroup(); err != nil {
+			return "", false, fmt.Errorf("array: %w", err)
+		}
+	}
+
+	atCellEnd := func() bool {
+		return p.src[p.pos] == '&' || p.peekCommand() == "\\" || p.peekCommand() == "end"
+	}
+
+	var rows [][]string
+	row := []string{}
+	for {
+		nodes, err := p.parseList(atCellEnd)
+		if err != nil {
+			return "", false, err
+		}
+		if p.eof() || p.src[p.pos] == '}' {
+			return "", false, fmt.Errorf("missing \\end{%s}", env)
+		}
+		row = append(row, mrow(nodes))
+
+		if p.src[p.pos] == '&' {
+			p.pos++
+			continue
+		}
+		if p.readCommand() == "\\" {
+			rows = append(rows, row)
+			row = []string{}
+			continue
+		}
+
+		end, err := p.readRawGroup()
+		if err != nil {
+			return "", false, fmt.Errorf("\\end: %w", err)
+		}
+		if end != env {
+			return "", false, fmt.Errorf("\\begin{%s} ended by \\end{%s}", env, end)
+		}
+		if len(row) > 1 || row[0] != "<mrow></mrow>" {
+			rows = append(rows, row)
+		}
+		break
+	}
+
+	var b strings.Builder
+	b.WriteString("<mrow>")
+	if fences[0] != "" {
+		b.WriteString(`<mo fence="true" stretchy="true">` + html.EscapeString(fences[0]) + "</mo>")
+	}
+	b.WriteString("<mtable>")
+	for _, row := range rows {
+		b.WriteString("<mtr>")
+		for _, cell := range row {
+			b.WriteString("<mtd>" + cell + "</mtd>")
+		}
+		b.WriteString("</mtr>")
+	}
+	b.WriteString("</mtable>")
+	if fences[1] != "" {
+		b.WriteString(`<mo fence="true" stretchy="true">` + html.EscapeString(fences[1]) + "</mo>")
+	}
+	b.WriteString("</mrow>")
+	return b.String(), false, nil
+}
+
+// mrow wraps nodes in an mrow unless there is exactly one.
+func mrow(nodes []string) string {
+	if len(nodes) == 1 {
+		return nodes[0]
+	}
+	return "<mrow>" + strings.Join(nodes, "") + "</mrow>"
+}
+
+func isASCIILetter(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z'
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+var mathIdentifiers = map[string]string{
+	"alpha": "α", "beta": "β", "gamma": "γ", "delta": "δ", "epsilon": "ϵ",
+	"varepsilon": "ε", "zeta": "ζ", "eta": "η", "theta": "θ", "vartheta": "ϑ",
+	"iota": "ι", "kappa": "κ", "lambda": "λ", "mu": "μ", "nu": "ν", "xi": "ξ",
+	"pi": "π", "varpi": "ϖ", "rho": "ρ", "varrho": "ϱ", "sigma": "σ",
+	"varsigma": "ς", "tau": "τ", "upsilon": "υ", "phi": "ϕ", "varphi": "φ",
+	"chi": "χ", "psi": "ψ", "omega": "ω",
+	"Gamma": "Γ", "Delta": "Δ", "Theta": "Θ", "Lambda": "Λ", "Xi": "Ξ",
+	"Pi": "Π", "Sigma": "Σ", "Upsilon": "Υ", "Phi": "Φ", "Psi": "Ψ",
+	"Omega": "Ω",
+	"infty": "∞", "partial": "∂", "nabla": "∇", "ell": "ℓ", "hbar": "ℏ",
+	"emptyset": "∅", "varnothing": "∅", "aleph": "ℵ", "Re": "ℜ", "Im": "ℑ",
+	"wp": "℘", "imath": "ı", "jmath": "ȷ",
+}
+
+var mathOperators = map[string]string{
+	"pm": "±", "mp": "∓", "times": "×", "div": "÷", "cdot": "⋅", "ast": "∗",
+	"star": "⋆", "circ": "∘", "bullet": "∙", "leq": "≤", "le": "≤", "geq": "≥",
+	"ge": "≥", "neq": "≠", "ne": "≠", "approx": "≈", "equiv": "≡", "sim": "∼",
+	"simeq": "≃", "cong": "≅", "propto": "∝", "ll": "≪", "gg": "≫", "in": "∈",
+	"notin": "∉", "ni": "∋", "subset": "⊂", "supset": "⊃", "subseteq": "⊆",
+	"supseteq": "⊇", "cup": "∪", "cap": "∩", "setminus": "∖", "wedge": "∧",
+	"land": "∧", "vee": "∨", "lor": "∨", "neg": "¬", "lnot": "¬",
+	"forall": "∀", "exists": "∃", "nexists": "∄", "to": "→", "rightarrow": "→",
+	"leftarrow": "←", "gets": "←", "Rightarrow": "⇒", "Leftarrow": "⇐",
+	"leftrightarrow": "↔", "Leftrightarrow": "⇔", "implies": "⟹",
+	"iff": "⟺", "mapsto": "↦", "uparrow": "↑", "downarrow": "↓",
+	"ldots": "…", "dots": "…", "cdots": "⋯", "vdots": "⋮", "ddots": "⋱",
+	"langle": "⟨", "rangle": "⟩", "lfloor": "⌊", "rfloor": "⌋",
+	"lceil": "⌈", "rceil": "⌉", "mid": "∣", "parallel": "∥", "perp": "⊥",
+	"angle": "∠", "oplus": "⊕", "otimes": "⊗", "odot": "⊙", "prime": "′",
+	"colon": ":", "vert": "|", "Vert": "‖", "lbrace": "{", "rbrace": "}",
+	"{": "{", "}": "}", "|": "‖", "%": "%", "$": "$", "&": "&", "#": "#",
+	"_": "_", "models": "⊨", "vdash": "⊢", "therefore": "∴",
+	"because": "∵", "triangle": "△", "square": "□", "top": "⊤", "bot": "⊥",
+	"prec": "≺", "succ": "≻", "preceq": "⪯", "succeq": "⪰", "lt": "<",
+	"gt": ">", "backslash": "∖", "uplus": "⊎", "sqcup": "⊔", "sqcap": "⊓",
+}
+
+var mathLargeOperators = map[string]string{
+	"sum": "∑", "prod": "∏", "coprod": "∐", "bigcup": "⋃", "bigcap": "⋂",
+	"bigvee": "⋁", "bigwedge": "⋀", "bigoplus": "⨁", "bigotimes": "⨂",
+}
+
+var mathIntegrals = map[string]string{
+	"int": "∫", "iint": "∬", "iiint": "∭", "oint": "∮",
+}
+
+var mathFunctions = map[string]struct{}{
+	"sin": {}, "cos": {}, "tan": {}, "cot": {}, "sec": {}, "csc": {},
+	"arcsin": {}, "arccos": {}, "arctan": {}, "sinh": {}, "cosh": {},
+	"tanh": {}, "coth": {}, "log": {}, "ln": {}, "lg": {}, "exp": {},
+	"det": {}, "dim": {}, "ker": {}, "gcd": {}, "deg": {}, "arg": {},
+	"hom": {}, "Pr": {},
+}
+
+var mathLimitFunctions = map[string]string{
+	"lim": "lim", "limsup": "lim sup", "liminf": "lim inf", "max": "max",
+	"min": "min", "sup": "sup", "inf": "inf", "argmax": "arg max",
+	"argmin": "arg min",
+}
+
+var mathSpaces = map[string]string{
+	",": "0.1667em", ":": "0.2222em", ">": "0.2222em", ";": "0.2778em",
+	"!": "-0.1667em", " ": "0.3333em", "quad": "1em", "qquad": "2em",
+}
+
+var mathAccents = map[string]string{
+	"hat": "^", "widehat": "^", "bar": "¯", "vec": "→", "dot": "˙",
+	"ddot": "¨", "tilde": "~", "widetilde": "~", "check": "ˇ",
+	"breve": "˘", "acute": "´", "grave": "`",
+}
+
+type mathFont struct {
+	upper, lower, digit rune
+	special             map[rune]rune
+}
+
+// mathFonts maps \math<font> names to their Unicode mathematical
+// alphanumeric ranges. Letters that predate those ranges live elsewhere in
+// Unicode and are listed in special.
+var mathFonts = map[string]mathFont{
+	"rm": {},
+	"it": {upper: 0x1D434, lower: 0x1D44E, special: map[rune]rune{'h': 'ℎ'}},
+	"bf": {upper: 0x1D400, lower: 0x1D41A, digit: 0x1D7CE},
+	"sf": {upper: 0x1D5A0, lower: 0x1D5BA, digit: 0x1D7E2},
+	"tt": {upper: 0x1D670, lower: 0x1D68A, digit: 0x1D7F6},
+	"bb": {upper: 0x1D538, lower: 0x1D552, digit: 0x1D7D8, special: map[rune]rune{
+		'C': 'ℂ', 'H': 'ℍ', 'N': 'ℕ', 'P': 'ℙ', 'Q': 'ℚ', 'R': 'ℝ', 'Z': 'ℤ',
+	}},
+	"cal": {upper: 0x1D49C, lower: 0x1D4B6, special: map[rune]rune{
+		'B': 'ℬ', 'E': 'ℰ', 'F': 'ℱ', 'H': 'ℋ', 'I': 'ℐ', 'L': 'ℒ', 'M': 'ℳ',
+		'R': 'ℛ', 'e': 'ℯ', 'g': 'ℊ', 'o': 'ℴ',
+	}},
+	"frak": {upper: 0x1D504, lower: 0x1D51E, special: map[rune]rune{
+		'C': 'ℭ', 'H': 'ℌ', 'I': 'ℑ', 'R': 'ℜ', 'Z': 'ℨ',
+	}},
+}
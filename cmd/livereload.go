@@ -0,0 +1,198 @@
+/*
+Copyright © 2024 Brian Greenhill <brian@briangreenhill.net>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package cmd
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+const (
+	liveReloadPath       = "/__livereload"
+	liveReloadScriptPath = "/__livereload.js"
+)
+
+// liveReloadScript listens for rebuilds. A "css" event swaps the page's
+// stylesheets for the ones in the rebuilt page, whose fingerprinted URLs
+// and integrity hashes will have changed, without reloading; any other
+// change reloads the page.
+const liveReloadScript = `(function () {
+  var source = new EventSource("` + liveReloadPath + `");
+
+  source.addEventListener("reload", function () {
+    location.reload();
+  });
+
+  source.addEventListener("css", function () {
+    fetch(location.href, { cache: "no-store" })
+      .then(function (res) { return res.text(); })
+      .then(function (html) {
+        var doc = new DOMParser().parseFromString(html, "text/html");
+        var fresh = doc.querySelectorAll('link[rel="stylesheet"]');
+        var current = document.querySelectorAll('link[rel="stylesheet"]');
+        if (fresh.length !== current.length) {
+          location.reload();
+          return;
+        }
+        current.forEach(function (link, i) {
+          var href = fresh[i].getAttribute("href");
+          if (href === link.getAttribute("href")) {
+            href += (href.indexOf("?") < 0 ? "?" : "&") + "livereload=" + Date.now();
+          }
+          if (fresh[i].integrity) {
+            link.integrity = fresh[i].integrity;
+          } else {
+            link.removeAttribute("integrity");
+          }
+          link.href = href;
+        });
+      })
+      .catch(function () { location.reload(); });
+  });
+})();
+`
+
+// liveReload notifies connected browsers of rebuilds over Server-Sent
+// Events.
+type liveReload struct {
+	mu      sync.Mutex
+	clients map[chan string]struct{}
+
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+func newLiveReload() *liveReload {
+	return &liveReload{clients: map[chan string]struct{}{}, done: make(chan struct{})}
+}
+
+// close disconnects every browser, for when the server shuts down.
+func (l *liveReload) close() {
+	l.closeOnce.Do(func() {
+		close(l.done)
+	})
+}
+
+// notify sends event, either "reload" or "css", to every connected browser.
+func (l *liveReload) notify(event string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	for client := range l.clients {
+		select {
+		case client <- event:
+		default:
+			// the client already has an event pending
+		}
+	}
+}
+
+// ServeHTTP streams events to a browser until it disconnects.
+func (l *liveReload) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming unsupported", http.StatusInternalServerError)
+		return
+	}
+
+	client := make(chan string, 1)
+	l.mu.Lock()
+	l.clients[client] = struct{}{}
+	l.mu.Unlock()
+	defer func() {
+		l.mu.Lock()
+		delete(l.clients, client)
+		l.mu.Unlock()
+	}()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	fmt.Fprint(w, "retry: 1000\n\n")
+	flusher.Flush()
+
+	for {
+		select {
+		case event := <-client:
+			fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event, event)
+			flusher.Flush()
+		case <-r.Context().Done():
+			return
+		case <-l.done:
+			return
+		}
+	}
+}
+
+// injectLiveReload wraps next so that HTML responses load the live reload
+// script.
+func injectLiveReload(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// the whole response is buffered, so don't let next answer with a
+		// partial range of the uninjected page
+		r.Header.Del("Range")
+
+		rec := &bufferedResponse{header: http.Header{}, status: http.StatusOK}
+		next.ServeHTTP(rec, r)
+
+		body := rec.body.Bytes()
+		if (rec.status == http.StatusOK || rec.status == http.StatusNotFound) && strings.HasPrefix(rec.header.Get("Content-Type"), "text/html") {
+			tag := []byte(`<script src="` + liveReloadScriptPath + `"></script>`)
+			if i := bytes.LastIndex(body, []byte("</body>")); i >= 0 {
+				body = append(body[:i:i], append(tag, body[i:]...)...)
+			} else {
+				body = append(body, tag...)
+			}
+			rec.header.Set("Content-Length", strconv.Itoa(len(body)))
+		}
+
+		for k, v := range rec.header {
+			w.Header()[k] = v
+		}
+		w.WriteHeader(rec.status)
+		w.Write(body)
+	})
+}
+
+// bufferedResponse is an http.ResponseWriter that holds the response so it
+// can be rewritten before it is sent.
+type bufferedResponse struct {
+	header http.Header
+	status int
+	body   bytes.Buffer
+}
+
+func (b *bufferedResponse) Header() http.Header {
+	return b.header
+}
+
+func (b *bufferedResponse) WriteHeader(status int) {
+	b.status = status
+}
+
+func (b *bufferedResponse) Write(p []byte) (int, error) {
+	return b.body.Write(p)
+}
@@ -0,0 +1,269 @@
+/*
+Copyright © 2024 Brian Greenhill <brian@briangreenhill.net>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package cmd
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/parser"
+	"github.com/yuin/goldmark/renderer"
+	"github.com/yuin/goldmark/text"
+	"github.com/yuin/goldmark/util"
+)
+
+// mathExtension renders $inline$ and $$display$$ LaTeX as MathML at build
+// time. Conversion errors are collected in the parser context rather than
+// rendered, so generation can fail with the offending line.
+type mathExtension struct{}
+
+var (
+	kindMath      = ast.NewNodeKind("Math")
+	kindMathBlock = ast.NewNodeKind("MathBlock")
+	mathErrorsKey = parser.NewContextKey()
+)
+
+type mathInline struct {
+	ast.BaseInline
+	MathML string
+}
+
+func (n *mathInline) Kind() ast.NodeKind {
+	return kindMath
+}
+
+func (n *mathInline) Dump(source []byte, level int) {
+	ast.DumpHelper(n, source, level, nil, nil)
+}
+
+type mathBlock struct {
+	ast.BaseBlock
+	MathML string
+	closed bool
+}
+
+func (n *mathBlock) Kind() ast.NodeKind {
+	return kindMathBlock
+}
+
+func (n *mathBlock) IsRaw() bool {
+	return true
+}
+
+func (n *mathBlock) Dump(source []byte, level int) {
+	ast.DumpHelper(n, source, level, nil, nil)
+}
+
+// mathError is a LaTeX conversion error at an offset in the markdown source.
+type mathError struct {
+	offset int
+	err    error
+}
+
+func addMathError(pc parser.Context, offset int, err error) {
+	errs, _ := pc.Get(mathErrorsKey).([]mathError)
+	pc.Set(mathErrorsKey, append(errs, mathError{offset: offset, err: err}))
+}
+
+// mathErrors returns the errors recorded while parsing, in source order.
+func mathErrors(pc parser.Context) []mathError {
+	errs, _ := pc.Get(mathErrorsKey).([]mathError)
+	sort.SliceStable(errs, func(i, j int) bool {
+		return errs[i].offset < errs[j].offset
+	})
+	return errs
+}
+
+func (e *mathExtension) Extend(m goldmark.Markdown) {
+	m.Parser().AddOptions(
+		parser.WithBlockParsers(util.Prioritized(&mathBlockParser{}, 100)),
+		parser.WithInlineParsers(util.Prioritized(&mathParser{}, 100)),
+	)
+	m.Renderer().AddOptions(
+		renderer.WithNodeRenderers(util.Prioritized(&mathRenderer{}, 100)),
+	)
+}
+
+// mathParser parses $...$ and single-line $$...$$ within paragraphs. To
+// avoid treating prices as math, the opening $ must not be followed by a
+// space and the closing $ must not be preceded by a space or followed by a
+// digit.
+type mathParser struct{}
+
+func (s *mathParser) Trigger() []byte {
+	return []byte{'$'}
+}
+
+func (s *mathParser) Parse(parent ast.Node, block text.Reader, pc parser.Context) ast.Node {
+	line, segment := block.PeekLine()
+
+	if bytes.HasPrefix(line, []byte("$$")) {
+		end := bytes.Index(line[2:], []byte("$$"))
+		if end <= 0 {
+			return nil
+		}
+		block.Advance(end + 4)
+		return newMath(string(line[2:end+2]), true, segment.Start, pc)
+	}
+
+	if len(line) < 3 || line[1] == ' ' || line[1] == '\t' {
+		return nil
+	}
+	for i := 2; i < len(line); i++ {
+		switch line[i] {
+		case '\\':
+			i++
+		case '$':
+			if line[i-1] == ' ' || line[i-1] == '\t' || i+1 < len(line) && isDigit(line[i+1]) {
+				continue
+			}
+			block.Advance(i + 1)
+			return newMath(string(line[1:i]), false, segment.Start, pc)
+		}
+	}
+	return nil
+}
+
+func newMath(tex string, display bool, offset int, pc parser.Context) ast.Node {
+	mathml, err := latexToMathML(tex, display)
+	if err != nil {
+		addMathError(pc, offset, err)
+	}
+	return &mathInline{MathML: mathml}
+}
+
+// mathBlockParser parses $$ blocks, which may span several lines.
+type mathBlockParser struct{}
+
+func (b *mathBlockParser) Trigger() []byte {
+	return []byte{'$'}
+}
+
+func (b *mathBlockParser) Open(parent ast.Node, reader text.Reader, pc parser.Context) (ast.Node, parser.State) {
+	line, segment := reader.PeekLine()
+	pos := pc.BlockOffset()
+	if pos < 0 || !bytes.HasPrefix(line[pos:], []byte("$$")) {
+		return nil, parser.NoChildren
+	}
+
+	node := &mathBlock{}
+	rest := bytes.TrimRight(line[pos+2:], " \t\r\n")
+	start := segment.Start + pos + 2
+	if end := bytes.Index(rest, []byte("$$")); end >= 0 {
+		// $$x$$ followed by more text is display math within a
+		// paragraph, which the inline parser handles
+		if len(bytes.TrimSpace(rest[end+2:])) > 0 {
+			return nil, parser.NoChildren
+		}
+		node.Lines().Append(text.NewSegment(start, start+end))
+		node.closed = true
+	} else {
+		node.Lines().Append(text.NewSegment(start, start+len(rest)))
+	}
+	advancePastLine(reader, line, segment)
+	return node, parser.NoChildren
+}
+
+func (b *mathBlockParser) Continue(node ast.Node, reader text.Reader, pc parser.Context) parser.State {
+	n := node.(*mathBlock)
+	if n.closed {
+		return parser.Close
+	}
+
+	line, segment := reader.PeekLine()
+	if line == nil {
+		return parser.Close
+	}
+	trimmed := bytes.TrimRight(line, " \t\r\n")
+	if end := bytes.Index(trimmed, []byte("$$")); end >= 0 {
+		if len(bytes.TrimSpace(trimmed[end+2:])) > 0 {
+			addMathError(pc, segment.Start+end, fmt.Errorf("unexpected text after closing $$"))
+		}
+		n.Lines().Append(text.NewSegment(segment.Start, segment.Start+end))
+		n.closed = true
+		advancePastLine(reader, line, segment)
+		return parser.Close
+	}
+	n.Lines().Append(segment)
+	advancePastLine(reader, line, segment)
+	return parser.Continue | parser.NoChildren
+}
+
+func (b *mathBlockParser) Close(node ast.Node, reader text.Reader, pc parser.Context) {
+	n := node.(*mathBlock)
+	lines := n.Lines()
+
+	var tex bytes.Buffer
+	for i := 0; i < lines.Len(); i++ {
+		segment := lines.At(i)
+		tex.Write(segment.Value(reader.Source()))
+		tex.WriteByte('\n')
+	}
+
+	offset := 0
+	if lines.Len() > 0 {
+		offset = lines.At(0).Start
+	}
+	if !n.closed {
+		addMathError(pc, offset, fmt.Errorf("unclosed $$ block"))
+		return
+	}
+
+	mathml, err := latexToMathML(tex.String(), true)
+	if err != nil {
+		addMathError(pc, offset, err)
+	}
+	n.MathML = mathml
+}
+
+func (b *mathBlockParser) CanInterruptParagraph() bool {
+	return true
+}
+
+func (b *mathBlockParser) CanAcceptIndentedLine() bool {
+	return false
+}
+
+type mathRenderer struct{}
+
+func (r *mathRenderer) RegisterFuncs(reg renderer.NodeRendererFuncRegisterer) {
+	reg.Register(kindMath, r.renderMath)
+	reg.Register(kindMathBlock, r.renderMathBlock)
+}
+
+func (r *mathRenderer) renderMath(w util.BufWriter, source []byte, node ast.Node, entering bool) (ast.WalkStatus, error) {
+	if entering {
+		_, _ = w.WriteString(node.(*mathInline).MathML)
+	}
+	return ast.WalkSkipChildren, nil
+}
+
+func (r *mathRenderer) renderMathBlock(w util.BufWriter, source []byte, node ast.Node, entering bool) (ast.WalkStatus, error) {
+	if entering {
+		_, _ = w.WriteString(node.(*mathBlock).MathML)
+		_ = w.WriteByte('\n')
+	}
+	return ast.WalkSkipChildren, nil
+}
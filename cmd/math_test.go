@@ -0,0 +1,134 @@
+/*
+Copyright © 2024 Brian Greenhill <brian@briangreenhill.net>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package cmd
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/parser"
+	"github.com/yuin/goldmark/text"
+)
+
+// renderMath renders src with only the math extension, returning the HTML
+// and any LaTeX errors.
+func renderMath(t *testing.T, src string) (string, []mathError) {
+	t.Helper()
+	md := goldmark.New(goldmark.WithExtensions(&mathExtension{}))
+	pc := parser.NewContext()
+	doc := md.Parser().Parse(text.NewReader([]byte(src)), parser.WithContext(pc))
+	var buf bytes.Buffer
+	if err := md.Renderer().Render(&buf, []byte(src), doc); err != nil {
+		t.Fatalf("Render() error = %v", err)
+	}
+	return buf.String(), mathErrors(pc)
+}
+
+func TestMath(t *testing.T) {
+	tests := []struct {
+		name     string
+		src      string
+		contains []string
+		excludes []string
+		errors   int
+	}{
+		{
+			name:     "inline",
+			src:      "Energy is $E = mc^2$ here.\n",
+			contains: []string{"<p>Energy is <math", "</math> here.</p>"},
+			excludes: []string{"$"},
+		},
+		{
+			name:     "dollar amounts",
+			src:      "It costs $5 and $10 today.\n",
+			contains: []string{"<p>It costs $5 and $10 today.</p>"},
+			excludes: []string{"<math"},
+		},
+		{
+			name:     "dollar amount after a space",
+			src:      "Between $5 and 6 $ is text.\n",
+			contains: []string{"<p>Between $5 and 6 $ is text.</p>"},
+			excludes: []string{"<math"},
+		},
+		{
+			name:     "escaped dollars",
+			src:      "Also \\$escaped\\$.\n",
+			contains: []string{"<p>Also $escaped$.</p>"},
+			excludes: []string{"<math"},
+		},
+		{
+			name:     "display block",
+			src:      "$$\n\\frac{1}{2}\n$$\n\nAfter.\n",
+			contains: []string{`display="block"`, "<p>After.</p>"},
+			excludes: []string{"<p>$"},
+		},
+		{
+			name:     "display block at end of file",
+			src:      "$$\n\\frac{1}{2}\n$$",
+			contains: []string{`display="block"`},
+			excludes: []string{"$", "<p>"},
+		},
+		{
+			name:     "single line display block at end of file",
+			src:      "$$x^2$$",
+			contains: []string{`display="block"`},
+			excludes: []string{"$", "<p>"},
+		},
+		{
+			name:     "display math followed by text",
+			src:      "$$x^2$$ is a square.\n",
+			contains: []string{`display="block"`, "is a square.</p>"},
+			excludes: []string{"$"},
+		},
+		{
+			name:   "text after a closing fence",
+			src:    "$$\nx^2\n$$ trailing\n",
+			errors: 1,
+		},
+		{
+			name:   "unclosed block",
+			src:    "$$\nx^2\n",
+			errors: 1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, errs := renderMath(t, tt.src)
+			for _, s := range tt.contains {
+				if !strings.Contains(got, s) {
+					t.Errorf("render(%q) = %q, want it to contain %q", tt.src, got, s)
+				}
+			}
+			for _, s := range tt.excludes {
+				if strings.Contains(got, s) {
+					t.Errorf("render(%q) = %q, want it not to contain %q", tt.src, got, s)
+				}
+			}
+			if len(errs) != tt.errors {
+				t.Errorf("render(%q) gave %d errors %v, want %d", tt.src, len(errs), errs, tt.errors)
+			}
+		})
+	}
+}
@@ -0,0 +1,176 @@
+/*
+Copyright © 2024 Brian Greenhill <brian@briangreenhill.net>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package cmd
+
+import (
+	"bytes"
+	"io"
+	"io/fs"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// memoryFS holds a generated site in memory so that ssg watch can serve
+// builds without writing them to the output directory. Each build replaces
+// the previous one as a whole, so requests never see a half-finished
+// build.
+type memoryFS struct {
+	mu    sync.RWMutex
+	files map[string]*memoryFile
+}
+
+type memoryFile struct {
+	data    []byte
+	modTime time.Time
+}
+
+func newMemoryFS() *memoryFS {
+	return &memoryFS{files: map[string]*memoryFile{}}
+}
+
+// publish replaces the served site with files, keyed by slash-separated
+// path relative to the output directory. Files whose content hasn't changed
+// keep their modification time, so browsers can keep them cached.
+func (m *memoryFS) publish(files map[string][]byte) {
+	now := time.Now()
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	next := make(map[string]*memoryFile, len(files))
+	for name, data := range files {
+		if prev, ok := m.files[name]; ok && bytes.Equal(prev.data, data) {
+			next[name] = prev
+			continue
+		}
+		next[name] = &memoryFile{data: data, modTime: now}
+	}
+	m.files = next
+}
+
+// Open implements fs.FS.
+func (m *memoryFS) Open(name string) (fs.File, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrInvalid}
+	}
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	if f, ok := m.files[name]; ok {
+		return &openMemoryFile{info: memoryFileInfo{name: path.Base(name), size: int64(len(f.data)), modTime: f.modTime}, Reader: bytes.NewReader(f.data)}, nil
+	}
+
+	// anything that is a prefix of a file's path is a directory
+	prefix := name + "/"
+	if name == "." {
+		prefix = ""
+	}
+	seen := map[string]bool{}
+	var entries []fs.DirEntry
+	for file, f := range m.files {
+		rest, ok := strings.CutPrefix(file, prefix)
+		if !ok {
+			continue
+		}
+		entry, _, isDir := strings.Cut(rest, "/")
+		if seen[entry] {
+			continue
+		}
+		seen[entry] = true
+		info := memoryFileInfo{name: entry, dir: isDir, modTime: f.modTime}
+		if !isDir {
+			info.size = int64(len(f.data))
+		}
+		entries = append(entries, info)
+	}
+	if len(entries) == 0 && name != "." {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrNotExist}
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Name() < entries[j].Name() })
+
+	return &openMemoryDir{info: memoryFileInfo{name: path.Base(name), dir: true}, entries: entries}, nil
+}
+
+// memoryFileInfo implements both fs.FileInfo and fs.DirEntry.
+type memoryFileInfo struct {
+	name    string
+	size    int64
+	dir     bool
+	modTime time.Time
+}
+
+func (i memoryFileInfo) Name() string               { return i.name }
+func (i memoryFileInfo) Size() int64                { return i.size }
+func (i memoryFileInfo) ModTime() time.Time         { return i.modTime }
+func (i memoryFileInfo) IsDir() bool                { return i.dir }
+func (i memoryFileInfo) Sys() any                   { return nil }
+func (i memoryFileInfo) Type() fs.FileMode          { return i.Mode().Type() }
+func (i memoryFileInfo) Info() (fs.FileInfo, error) { return i, nil }
+
+func (i memoryFileInfo) Mode() fs.FileMode {
+	if i.dir {
+		return fs.ModeDir | 0755
+	}
+	return 0644
+}
+
+type openMemoryFile struct {
+	info memoryFileInfo
+	*bytes.Reader
+}
+
+func (f *openMemoryFile) Stat() (fs.FileInfo, error) { return f.info, nil }
+func (f *openMemoryFile) Close() error               { return nil }
+
+type openMemoryDir struct {
+	info    memoryFileInfo
+	entries []fs.DirEntry
+	offset  int
+}
+
+func (d *openMemoryDir) Stat() (fs.FileInfo, error) { return d.info, nil }
+func (d *openMemoryDir) Close() error               { return nil }
+
+func (d *openMemoryDir) Read([]byte) (int, error) {
+	return 0, &fs.PathError{Op: "read", Path: d.info.name, Err: fs.ErrInvalid}
+}
+
+// ReadDir implements fs.ReadDirFile.
+func (d *openMemoryDir) ReadDir(n int) ([]fs.DirEntry, error) {
+	rest := d.entries[d.offset:]
+	if n <= 0 {
+		d.offset = len(d.entries)
+		return rest, nil
+	}
+	if len(rest) == 0 {
+		return nil, io.EOF
+	}
+	if n > len(rest) {
+		n = len(rest)
+	}
+	d.offset += n
+	return rest[:n], nil
+}
@@ -22,6 +22,7 @@ THE SOFTWARE.
 package cmd
 
 import (
+	"context"
 	"fmt"
 	"log"
 	"os"
@@ -79,6 +80,10 @@ func createSite() error {
 					Title("Enter a description of the site").
 					Placeholder("A site about things").
 					Value(&cfg.Description),
+				huh.NewInput().
+					Title("Enter the URL the site will be published at").
+					Placeholder("https://example.com").
+					Value(&cfg.BaseURL),
 			),
 			huh.NewGroup(
 				huh.NewInput().
@@ -145,6 +150,7 @@ func createSite() error {
 			cfg.Linkedin = "https://linkedin.com/in/mona"
 		}
 
+		viper.Set("baseURL", cfg.BaseURL)
 		viper.Set("theme", cfg.Theme)
 		viper.Set("contentDir", cfg.ContentDir)
 		viper.Set("outputDir", cfg.OutputDir)
@@ -173,7 +179,7 @@ func createSite() error {
 		return fmt.Errorf("unable to decode into struct: %w", err)
 	}
 
-	if err := generateSite(&cfg); err != nil {
+	if err := generateSite(context.Background(), &cfg); err != nil {
 		return err
 	}
 
@@ -0,0 +1,275 @@
+/*
+Copyright © 2024 Brian Greenhill <brian@briangreenhill.net>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// outputFiles records the files written to the output directory during a
+// build, so that files left behind by earlier builds, such as the page of a
+// renamed post, can be removed.
+type outputFiles struct {
+	dir   string
+	cache *buildCache
+	// memory, when set, receives the build in place of the output
+	// directory; see publish
+	memory *memoryFS
+
+	mu    sync.Mutex
+	files map[string]bool
+	data  map[string][]byte
+}
+
+func newOutputFiles(dir string, cache *buildCache, memory *memoryFS) *outputFiles {
+	return &outputFiles{dir: dir, cache: cache, memory: memory, files: map[string]bool{}, data: map[string][]byte{}}
+}
+
+// add records that the file at path, which is inside the output directory,
+// was produced by this build.
+func (o *outputFiles) add(path string) {
+	rel, err := filepath.Rel(o.dir, path)
+	if err != nil {
+		return
+	}
+	o.mu.Lock()
+	o.files[rel] = true
+	o.mu.Unlock()
+}
+
+// writeFile writes b to path and records it. The write is skipped when the
+// file already holds b according to the build cache. When building into
+// memory, b is kept until publish instead.
+func (o *outputFiles) writeFile(path string, b []byte) error {
+	o.add(path)
+	if o.memory != nil {
+		rel, err := filepath.Rel(o.dir, path)
+		if err != nil {
+			return err
+		}
+		o.mu.Lock()
+		o.data[filepath.ToSlash(rel)] = append([]byte(nil), b...)
+		o.mu.Unlock()
+		return nil
+	}
+	if o.cache.unchanged(path, hashBytes(b), len(b)) {
+		return nil
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		o.cache.forget(path)
+		return fmt.Errorf("error creating directory %s: %w", filepath.Dir(path), err)
+	}
+	if err := writeFileAtomic(path, b); err != nil {
+		o.cache.forget(path)
+		return err
+	}
+	return nil
+}
+
+// writeFileAtomic writes b to a temporary file next to path and renames it
+// into place, so readers such as the dev server never see a partly written
+// file. The temporary file is a dotfile, which stale file removal ignores.
+func writeFileAtomic(path string, b []byte) error {
+	f, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	tmp := f.Name()
+
+	_, err = f.Write(b)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Chmod(tmp, 0644)
+	}
+	if err == nil {
+		err = os.Rename(tmp, path)
+	}
+	if err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("error writing %s: %w", path, err)
+	}
+	return nil
+}
+
+// copyFile copies src to dst and records dst.
+func (o *outputFiles) copyFile(src, dst string) error {
+	b, err := os.ReadFile(src)
+	if err != nil {
+		return fmt.Errorf("error reading file to copy: %w", err)
+	}
+	return o.writeFile(dst, b)
+}
+
+// publish makes an in-memory build visible to the server.
+func (o *outputFiles) publish() {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.memory.publish(o.data)
+}
+
+// outputsListName is the file in the cache directory listing what the last
+// build wrote. It's kept apart from the build cache so that it's still used
+// with --no-cache.
+const outputsListName = "outputs.json"
+
+// outputsList records the files a build wrote to its output directory,
+// relative to it.
+type outputsList struct {
+	Dir   string   `json:"dir"`
+	Files []string `json:"files"`
+}
+
+// removeStale deletes the files that the previous build wrote but this one
+// didn't, such as the page of a renamed post, along with directories left
+// empty. Files that ssg didn't write, such as a CNAME or robots.txt, are
+// left alone; --clean removes those.
+func (o *outputFiles) removeStale(cfg *config) error {
+	if err := checkOutputDir(o.dir, cfg.ContentDir, "themes"); err != nil {
+		return err
+	}
+
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	listFile := filepath.Join(cacheDirName, outputsListName)
+	var prev outputsList
+	if b, err := os.ReadFile(listFile); err == nil {
+		if err := json.Unmarshal(b, &prev); err != nil {
+			fmt.Printf("warning: ignoring unreadable list of output files: %v\n", err)
+			prev = outputsList{}
+		}
+	}
+
+	// a list for another output directory says nothing about this one
+	if filepath.Clean(prev.Dir) == filepath.Clean(o.dir) {
+		for _, rel := range prev.Files {
+			rel = filepath.FromSlash(rel)
+			if o.files[rel] || !filepath.IsLocal(rel) {
+				continue
+			}
+			path := filepath.Join(o.dir, rel)
+			if _, err := os.Lstat(path); os.IsNotExist(err) {
+				continue
+			}
+			fmt.Println("removing stale file: ", path)
+			if err := os.Remove(path); err != nil {
+				return fmt.Errorf("error removing stale file: %w", err)
+			}
+			// remove any directories this leaves empty; os.Remove fails on
+			// the first one that still has files in it
+			for dir := filepath.Dir(path); dir != o.dir && strings.HasPrefix(dir, o.dir); dir = filepath.Dir(dir) {
+				if os.Remove(dir) != nil {
+					break
+				}
+			}
+		}
+	}
+
+	list := outputsList{Dir: o.dir, Files: make([]string, 0, len(o.files))}
+	for rel := range o.files {
+		list.Files = append(list.Files, filepath.ToSlash(rel))
+	}
+	sort.Strings(list.Files)
+	b, err := json.Marshal(list)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(cacheDirName, 0755); err != nil {
+		return err
+	}
+	return writeFileAtomic(listFile, b)
+}
+
+// cleanOutputDir removes everything in the output directory except
+// dotfiles, after checking that it is safe to do so.
+func cleanOutputDir(cfg *config) error {
+	if err := checkOutputDir(cfg.OutputDir, cfg.ContentDir, "themes"); err != nil {
+		return err
+	}
+
+	entries, err := os.ReadDir(cfg.OutputDir)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	fmt.Println("cleaning output directory: ", cfg.OutputDir)
+	for _, entry := range entries {
+		if strings.HasPrefix(entry.Name(), ".") {
+			continue
+		}
+		if err := os.RemoveAll(filepath.Join(cfg.OutputDir, entry.Name())); err != nil {
+			return fmt.Errorf("error cleaning output directory: %w", err)
+		}
+	}
+	return nil
+}
+
+// checkOutputDir refuses output directories that deleting files from could
+// destroy something other than generated output: the filesystem root, the
+// home directory, the working directory, or any directory containing it or
+// one of the protected directories.
+func checkOutputDir(dir string, protected ...string) error {
+	if strings.TrimSpace(dir) == "" {
+		return fmt.Errorf("no output directory configured")
+	}
+
+	abs, err := filepath.Abs(dir)
+	if err != nil {
+		return err
+	}
+	wd, err := os.Getwd()
+	if err != nil {
+		return err
+	}
+
+	if abs == filepath.Dir(abs) {
+		return fmt.Errorf("refusing to delete files in %s: it is the filesystem root", dir)
+	}
+	if home, err := os.UserHomeDir(); err == nil && abs == filepath.Clean(home) {
+		return fmt.Errorf("refusing to delete files in %s: it is the home directory", dir)
+	}
+	if rel, err := filepath.Rel(abs, wd); err == nil && !strings.HasPrefix(rel, "..") {
+		return fmt.Errorf("refusing to delete files in %s: it contains the working directory", dir)
+	}
+	for _, p := range protected {
+		pabs, err := filepath.Abs(p)
+		if err != nil {
+			return err
+		}
+		if rel, err := filepath.Rel(abs, pabs); err == nil && !strings.HasPrefix(rel, "..") {
+			return fmt.Errorf("refusing to delete files in %s: it contains %s", dir, p)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,158 @@
+/*
+Copyright © 2024 Brian Greenhill <brian@briangreenhill.net>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// chdir changes to dir for the rest of the test.
+func chdir(t *testing.T, dir string) {
+	t.Helper()
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(dir); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.Chdir(wd) })
+}
+
+func TestCheckOutputDir(t *testing.T) {
+	dir := t.TempDir()
+	home := filepath.Join(dir, "home")
+	site := filepath.Join(dir, "site")
+	for _, d := range []string{home, site} {
+		if err := os.MkdirAll(d, 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	t.Setenv("HOME", home)
+	chdir(t, site)
+
+	tests := []struct {
+		name    string
+		dir     string
+		wantErr bool
+	}{
+		{"output directory", "public", false},
+		{"nested output directory", "build/public", false},
+		{"sibling of the working directory", "../public", false},
+		{"empty", "", true},
+		{"filesystem root", "/", true},
+		{"home directory", home, true},
+		{"working directory", ".", true},
+		{"parent of the working directory", "..", true},
+		{"content directory", "content", true},
+		{"parent of the content directory", "src", true},
+		{"themes", "themes", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := checkOutputDir(tt.dir, filepath.Join("src", "content"), "content", "themes")
+			if (err != nil) != tt.wantErr {
+				t.Errorf("checkOutputDir(%q) error = %v, wantErr %v", tt.dir, err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func writeTestFile(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestRemoveStale(t *testing.T) {
+	chdir(t, t.TempDir())
+	cfg := &config{ContentDir: "content", OutputDir: "public"}
+
+	// files that ssg didn't write
+	writeTestFile(t, "public/CNAME", "example.com")
+	writeTestFile(t, "public/robots.txt", "User-agent: *")
+	writeTestFile(t, "public/.git/HEAD", "ref: refs/heads/main")
+
+	build := func(files ...string) {
+		t.Helper()
+		output := newOutputFiles(cfg.OutputDir, loadBuildCache("", false), nil)
+		for _, f := range files {
+			if err := output.writeFile(filepath.Join(cfg.OutputDir, f), []byte(f)); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if err := output.removeStale(cfg); err != nil {
+			t.Fatalf("removeStale() error = %v", err)
+		}
+	}
+
+	build("index.html", "posts/old.html", "posts/kept.html")
+	build("index.html", "posts/new.html", "posts/kept.html")
+
+	for _, f := range []string{"CNAME", "robots.txt", ".git/HEAD", "index.html", "posts/new.html", "posts/kept.html"} {
+		if _, err := os.Stat(filepath.Join("public", f)); err != nil {
+			t.Errorf("%s was removed: %v", f, err)
+		}
+	}
+	if _, err := os.Stat("public/posts/old.html"); !os.IsNotExist(err) {
+		t.Errorf("stale posts/old.html wasn't removed")
+	}
+
+	// directories emptied by the removal go too
+	build("index.html")
+	if _, err := os.Stat("public/posts"); !os.IsNotExist(err) {
+		t.Errorf("empty posts directory wasn't removed")
+	}
+	if _, err := os.Stat("public/CNAME"); err != nil {
+		t.Errorf("CNAME was removed: %v", err)
+	}
+}
+
+func TestRemoveStaleOtherOutputDir(t *testing.T) {
+	chdir(t, t.TempDir())
+	cfg := &config{ContentDir: "content", OutputDir: "public"}
+
+	output := newOutputFiles("public", loadBuildCache("", false), nil)
+	if err := output.writeFile("public/index.html", nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := output.removeStale(cfg); err != nil {
+		t.Fatal(err)
+	}
+
+	// a file with the same name in a different output directory isn't one
+	// the last build wrote
+	writeTestFile(t, "dist/index.html", "mine")
+	cfg.OutputDir = "dist"
+	if err := newOutputFiles("dist", loadBuildCache("", false), nil).removeStale(cfg); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat("dist/index.html"); err != nil {
+		t.Errorf("dist/index.html was removed: %v", err)
+	}
+}
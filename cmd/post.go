@@ -95,6 +95,7 @@ date: %s
 author: %s
 author_image: %s
 description: %s
+tags: []
 draft: true
 ---
 
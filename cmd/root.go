@@ -24,6 +24,7 @@ package cmd
 import (
 	"fmt"
 	"os"
+	"runtime"
 
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
@@ -32,6 +33,8 @@ import (
 var (
 	cfgFile       string
 	includeDrafts bool
+	noCache       bool
+	workers       int
 )
 
 // rootCmd represents the base command when called without any subcommands
@@ -57,7 +60,9 @@ func init() {
 	// will be global for your application.
 
 	rootCmd.PersistentFlags().StringVar(&cfgFile, "config", "", "config file (default is $HOME/.ssg.yaml)")
-	rootCmd.PersistentFlags().BoolVar(&includeDrafts, "drafts", false, "include draft posts")
+	rootCmd.PersistentFlags().BoolVar(&includeDrafts, "drafts", false, "include draft and future-dated posts")
+	rootCmd.PersistentFlags().IntVar(&workers, "workers", runtime.NumCPU(), "number of posts to render at once")
+	rootCmd.PersistentFlags().BoolVar(&noCache, "no-cache", false, "ignore the build cache and rebuild everything")
 
 	// Cobra also supports local flags, which will only run
 	// when this action is called directly.
@@ -89,6 +94,13 @@ func initConfig() {
 		viper.SetDefault("github", "https://github.com/mona")
 		viper.SetDefault("linkedin", "https://linkedin.com/in/mona")
 		viper.SetDefault("email", "user@example.net")
+		viper.SetDefault("images.widths", []int{480, 960, 1440})
+		viper.SetDefault("images.sizes", "(max-width: 640px) 100vw, 600px")
+		viper.SetDefault("images.quality", 80)
+		viper.SetDefault("minify.css", true)
+		viper.SetDefault("minify.js", true)
+		viper.SetDefault("minify.html", false)
+		viper.SetDefault("search.content", true)
 	}
 
 	viper.AutomaticEnv() // read in environment variables that match
@@ -0,0 +1,939 @@
+/*
+Copyright © 2024 Brian Greenhill <brian@briangreenhill.net>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+const stylesDirName = "styles"
+
+// compileStyles compiles each non-partial .scss file in the theme's styles
+// directory to a CSS file of the same name in the output assets directory.
+// In watch mode a source map is written alongside each stylesheet;
+// otherwise the CSS is minified when CSS minification is enabled.
+func compileStyles(themeDir string, output *outputFiles, cfg minifyConfig, manifest *assetManifest) error {
+	stylesDir := filepath.Join(themeDir, stylesDirName)
+	entries, err := os.ReadDir(stylesDir)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	m := newMinifier()
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || filepath.Ext(name) != ".scss" || strings.HasPrefix(name, "_") {
+			continue
+		}
+
+		fmt.Println("compiling stylesheet: ", name)
+		css, srcMap, err := compileSCSS(filepath.Join(stylesDir, name), watchMode)
+		if err != nil {
+			return err
+		}
+
+		cssName := strings.TrimSuffix(name, ".scss") + ".css"
+		out := []byte(css)
+		switch {
+		case srcMap != nil:
+			if err := output.writeFile(filepath.Join(output.dir, assetsDirName, cssName+".map"), srcMap); err != nil {
+				return err
+			}
+			out = append(out, "/*# sourceMappingURL="+cssName+".map */\n"...)
+		case cfg.CSS:
+			if out, err = m.Bytes("text/css", out); err != nil {
+				return fmt.Errorf("error minifying %s: %w", cssName, err)
+			}
+		}
+
+		if err := output.writeFile(filepath.Join(output.dir, assetsDirName, cssName), out); err != nil {
+			return err
+		}
+		// the fingerprinted copy sits beside the source map too, so its
+		// sourceMappingURL still resolves
+		if _, err := manifest.fingerprint(cssName, out); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// compileSCSS compiles an SCSS file to CSS. It implements the commonly
+// used subset of Sass: variables, nesting and the parent selector, partials
+// via @import and @use, mixins with @content, @media bubbling,
+// interpolation and arithmetic on numbers. Errors are reported with the
+// SCSS file and line. When sourceMap is true it also returns a version 3
+// source map for the output.
+func compileSCSS(file string, sourceMap bool) (css string, srcMap []byte, err error) {
+	c := &scssCompiler{
+		mixins:   map[string]*scssMixin{},
+		imported: map[string]bool{},
+		global:   &scssScope{vars: map[string]string{}},
+	}
+	nodes, err := c.parseFile(file)
+	if err != nil {
+		return "", nil, err
+	}
+	if err := c.compile(nodes, scssContext{scope: c.global}, nil); err != nil {
+		return "", nil, err
+	}
+
+	w := &cssWriter{sources: map[string]int{}}
+	w.write(c.raw, c.blocks)
+	if !sourceMap {
+		return w.b.String(), nil, nil
+	}
+
+	srcMap, err = w.sourceMap(filepath.Base(strings.TrimSuffix(file, filepath.Ext(file)) + ".css"))
+	if err != nil {
+		return "", nil, err
+	}
+	return w.b.String(), srcMap, nil
+}
+
+// scssError is an error at a position in an SCSS file.
+type scssError struct {
+	file string
+	line int
+	msg  string
+}
+
+func (e *scssError) Error() string {
+	return fmt.Sprintf("%s:%d: %s", e.file, e.line, e.msg)
+}
+
+type scssKind int
+
+const (
+	scssRule scssKind = iota
+	scssDecl
+	scssVar
+	scssAtRule
+)
+
+type scssNode struct {
+	kind     scssKind
+	file     string
+	line     int
+	text     string // selector, property, variable or at-rule name
+	value    string // declaration or variable value, or at-rule params
+	hasBlock bool
+	children []*scssNode
+}
+
+func (n *scssNode) errorf(format string, args ...any) error {
+	return &scssError{file: n.file, line: n.line, msg: fmt.Sprintf(format, args...)}
+}
+
+// scssParser turns SCSS source into a tree of statements.
+type scssParser struct {
+	file string
+	src  string
+	pos  int
+	line int
+}
+
+func (c *scssCompiler) parseFile(file string) ([]*scssNode, error) {
+	b, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	c.imported[file] = true
+	p := &scssParser{file: file, src: string(b), line: 1}
+	return p.parseBlock(false)
+}
+
+func (p *scssParser) errorf(format string, args ...any) error {
+	return &scssError{file: p.file, line: p.line, msg: fmt.Sprintf(format, args...)}
+}
+
+func (p *scssParser) eof() bool {
+	return p.pos >= len(p.src)
+}
+
+func (p *scssParser) advance(n int) {
+	for i := 0; i < n && p.pos < len(p.src); i++ {
+		if p.src[p.pos] == '\n' {
+			p.line++
+		}
+		p.pos++
+	}
+}
+
+// skipSpace skips whitespace and comments.
+func (p *scssParser) skipSpace() {
+	for !p.eof() {
+		switch {
+		case strings.HasPrefix(p.src[p.pos:], "//"):
+			for !p.eof() && p.src[p.pos] != '\n' {
+				p.pos++
+			}
+		case strings.HasPrefix(p.src[p.pos:], "/*"):
+			end := strings.Index(p.src[p.pos+2:], "*/")
+			if end < 0 {
+				p.advance(len(p.src) - p.pos)
+				return
+			}
+			p.advance(end + 4)
+		case strings.ContainsRune(" \t\r\n", rune(p.src[p.pos])):
+			p.advance(1)
+		default:
+			return
+		}
+	}
+}
+
+// readPrelude reads up to the next ';', '{' or '}' that isn't inside a
+// string, parentheses or interpolation, and returns it with the
+// terminator. Comments are dropped.
+func (p *scssParser) readPrelude() (string, byte, error) {
+	var b strings.Builder
+	depth := 0
+	for !p.eof() {
+		c := p.src[p.pos]
+		switch {
+		case c == '"' || c == '\'':
+			start := p.pos
+			p.advance(1)
+			for !p.eof() && p.src[p.pos] != c {
+				if p.src[p.pos] == '\\' {
+					p.advance(1)
+				}
+				p.advance(1)
+			}
+			if p.eof() {
+				return "", 0, p.errorf("unterminated string")
+			}
+			p.advance(1)
+			b.WriteString(p.src[start:p.pos])
+			continue
+		case c == '/' && depth == 0 && strings.HasPrefix(p.src[p.pos:], "//"),
+			c == '/' && strings.HasPrefix(p.src[p.pos:], "/*"):
+			p.skipSpace()
+			b.WriteByte(' ')
+			continue
+		case c == '#' && strings.HasPrefix(p.src[p.pos:], "#{"):
+			end := strings.IndexByte(p.src[p.pos:], '}')
+			if end < 0 {
+				return "", 0, p.errorf("unterminated interpolation")
+			}
+			b.WriteString(p.src[p.pos : p.pos+end+1])
+			p.advance(end + 1)
+			continue
+		case c == '(' || c == '[':
+			depth++
+		case c == ')' || c == ']':
+			depth--
+		case depth == 0 && (c == ';' || c == '{' || c == '}'):
+			return b.String(), c, nil
+		}
+		b.WriteByte(c)
+		p.advance(1)
+	}
+	return b.String(), 0, nil
+}
+
+func (p *scssParser) parseBlock(nested bool) ([]*scssNode, error) {
+	var nodes []*scssNode
+	for {
+		p.skipSpace()
+		if p.eof() {
+			if nested {
+				return nil, p.errorf("missing }")
+			}
+			return nodes, nil
+		}
+		switch p.src[p.pos] {
+		case '}':
+			if !nested {
+				return nil, p.errorf("unexpected }")
+			}
+			p.advance(1)
+			return nodes, nil
+		case ';':
+			p.advance(1)
+			continue
+		}
+
+		line := p.line
+		prelude, term, err := p.readPrelude()
+		if err != nil {
+			return nil, err
+		}
+		prelude = strings.TrimSpace(prelude)
+		node := &scssNode{file: p.file, line: line}
+
+		if term == '{' {
+			p.advance(1)
+			node.hasBlock = true
+			if node.children, err = p.parseBlock(true); err != nil {
+				return nil, err
+			}
+			if strings.HasPrefix(prelude, "@") {
+				node.kind = scssAtRule
+				node.text, node.value, _ = strings.Cut(prelude[1:], " ")
+				node.value = strings.TrimSpace(node.value)
+			} else {
+				node.kind = scssRule
+				node.text = prelude
+			}
+			nodes = append(nodes, node)
+			continue
+		}
+
+		if term == ';' {
+			p.advance(1)
+		}
+		switch {
+		case strings.HasPrefix(prelude, "@"):
+			node.kind = scssAtRule
+			node.text, node.value, _ = strings.Cut(prelude[1:], " ")
+			node.value = strings.TrimSpace(node.value)
+		case strings.HasPrefix(prelude, "$"):
+			name, value, ok := strings.Cut(prelude, ":")
+			if !ok {
+				return nil, node.errorf("expected : after %s", name)
+			}
+			node.kind = scssVar
+			node.text = strings.TrimSpace(name)
+			node.value = strings.TrimSpace(value)
+		default:
+			prop, value, ok := strings.Cut(prelude, ":")
+			if !ok {
+				return nil, node.errorf("expected a declaration, found %q", prelude)
+			}
+			node.kind = scssDecl
+			node.text = strings.TrimSpace(prop)
+			node.value = strings.TrimSpace(value)
+		}
+		nodes = append(nodes, node)
+	}
+}
+
+type scssScope struct {
+	vars   map[string]string
+	parent *scssScope
+}
+
+func (s *scssScope) lookup(name string) (string, bool) {
+	for ; s != nil; s = s.parent {
+		if v, ok := s.vars[name]; ok {
+			return v, true
+		}
+	}
+	return "", false
+}
+
+type scssMixin struct {
+	params   []string
+	defaults map[string]string
+	body     []*scssNode
+}
+
+// scssContext is the state a block of statements is compiled in.
+type scssContext struct {
+	selectors []string
+	wrappers  []string // enclosing at-rules such as @media
+	scope     *scssScope
+	content   []*scssNode
+	contentCx *scssContext
+}
+
+type cssDecl struct {
+	prop, value string
+	file        string
+	line        int
+}
+
+type cssBlock struct {
+	wrappers []string
+	selector string
+	decls    []cssDecl
+	file     string
+	line     int
+}
+
+type scssCompiler struct {
+	mixins   map[string]*scssMixin
+	imported map[string]bool
+	global   *scssScope
+	raw      []string // top-level statements such as @charset and CSS @import
+	blocks   []*cssBlock
+}
+
+func (c *scssCompiler) compile(nodes []*scssNode, ctx scssContext, block *cssBlock) error {
+	for _, n := range nodes {
+		if err := c.compileNode(n, ctx, block); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (c *scssCompiler) compileNode(n *scssNode, ctx scssContext, block *cssBlock) error {
+	switch n.kind {
+	case scssVar:
+		value, flags := n.value, ""
+		for _, flag := range []string{"!default", "!global"} {
+			if strings.HasSuffix(value, flag) {
+				value = strings.TrimSpace(strings.TrimSuffix(value, flag))
+				flags += flag
+			}
+		}
+		name := strings.TrimPrefix(n.text, "$")
+		scope := ctx.scope
+		if strings.Contains(flags, "!global") {
+			scope = c.global
+		}
+		if _, ok := scope.lookup(name); ok && strings.Contains(flags, "!default") {
+			return nil
+		}
+		v, err := c.evaluate(n, value, ctx.scope)
+		if err != nil {
+			return err
+		}
+		scope.vars[name] = v
+		return nil
+
+	case scssDecl:
+		if block == nil {
+			return n.errorf("declaration %q is not inside a rule", n.text)
+		}
+		prop, err := c.interpolate(n, n.text, ctx.scope)
+		if err != nil {
+			return err
+		}
+		value, err := c.evaluate(n, n.value, ctx.scope)
+		if err != nil {
+			return err
+		}
+		block.decls = append(block.decls, cssDecl{prop: prop, value: value, file: n.file, line: n.line})
+		return nil
+
+	case scssRule:
+		sel, err := c.interpolate(n, n.text, ctx.scope)
+		if err != nil {
+			return err
+		}
+		selectors := resolveSelectors(ctx.selectors, sel)
+		b := &cssBlock{wrappers: ctx.wrappers, selector: strings.Join(selectors, ",\n"), file: n.file, line: n.line}
+		c.blocks = append(c.blocks, b)
+		child := ctx
+		child.selectors = selectors
+		child.scope = &scssScope{vars: map[string]string{}, parent: ctx.scope}
+		return c.compile(n.children, child, b)
+	}
+
+	return c.compileAtRule(n, ctx, block)
+}
+
+func (c *scssCompiler) compileAtRule(n *scssNode, ctx scssContext, block *cssBlock) error {
+	switch n.text {
+	case "import", "use", "forward":
+		for _, target := range splitTopLevel(n.value, ',') {
+			target = strings.TrimSpace(target)
+			if n.text != "import" {
+				// namespaces aren't supported, so members are used unprefixed
+				target, _, _ = strings.Cut(target, " as ")
+				target, _, _ = strings.Cut(target, " with ")
+				target = strings.TrimSpace(target)
+			}
+			name := unquote(target)
+			if n.text == "import" && (strings.HasSuffix(name, ".css") || strings.HasPrefix(target, "url(") || isExternalURL(name)) {
+				c.raw = append(c.raw, "@import "+target+";")
+				continue
+			}
+			if strings.HasPrefix(name, "sass:") {
+				continue
+			}
+			file, err := resolvePartial(filepath.Dir(n.file), name)
+			if err != nil {
+				return n.errorf("%v", err)
+			}
+			if c.imported[file] && n.text != "import" {
+				continue
+			}
+			nodes, err := c.parseFile(file)
+			if err != nil {
+				return err
+			}
+			if err := c.compile(nodes, ctx, block); err != nil {
+				return err
+			}
+		}
+		return nil
+
+	case "mixin":
+		name, params := splitCall(n.value)
+		m := &scssMixin{defaults: map[string]string{}, body: n.children}
+		for _, param := range params {
+			pname, def, hasDefault := strings.Cut(param, ":")
+			pname = strings.TrimPrefix(strings.TrimSpace(pname), "$")
+			m.params = append(m.params, pname)
+			if hasDefault {
+				m.defaults[pname] = strings.TrimSpace(def)
+			}
+		}
+		c.mixins[name] = m
+		return nil
+
+	case "include":
+		name, args := splitCall(n.value)
+		m, ok := c.mixins[name]
+		if !ok {
+			return n.errorf("undefined mixin %q", name)
+		}
+		scope := &scssScope{vars: map[string]string{}, parent: c.global}
+		for i, param := range m.params {
+			var value string
+			var ok bool
+			if i < len(args) {
+				value, ok = strings.TrimSpace(args[i]), true
+				if pname, v, named := strings.Cut(value, ":"); named && strings.HasPrefix(pname, "$") {
+					param, value = strings.TrimPrefix(strings.TrimSpace(pname), "$"), strings.TrimSpace(v)
+				}
+			}
+			if !ok {
+				value, ok = m.defaults[param]
+			}
+			if !ok {
+				return n.errorf("missing argument $%s for mixin %q", param, name)
+			}
+			v, err := c.evaluate(n, value, ctx.scope)
+			if err != nil {
+				return err
+			}
+			scope.vars[param] = v
+		}
+		mixinCtx := ctx
+		mixinCtx.scope = scope
+		mixinCtx.content = n.children
+		caller := ctx
+		mixinCtx.contentCx = &caller
+		return c.compile(m.body, mixinCtx, block)
+
+	case "content":
+		if ctx.contentCx == nil {
+			return nil
+		}
+		contentCtx := *ctx.contentCx
+		contentCtx.selectors = ctx.selectors
+		contentCtx.wrappers = ctx.wrappers
+		return c.compile(ctx.content, contentCtx, block)
+
+	case "media", "supports", "container", "layer":
+		params, err := c.evaluate(n, n.value, ctx.scope)
+		if err != nil {
+			return err
+		}
+		wrapper := "@" + n.text + " " + params
+		if !n.hasBlock {
+			c.raw = append(c.raw, wrapper+";")
+			return nil
+		}
+		child := ctx
+		child.wrappers = append(append([]string{}, ctx.wrappers...), wrapper)
+		var inner *cssBlock
+		if len(ctx.selectors) > 0 {
+			inner = &cssBlock{wrappers: child.wrappers, selector: strings.Join(ctx.selectors, ",\n"), file: n.file, line: n.line}
+			c.blocks = append(c.blocks, inner)
+		}
+		return c.compile(n.children, child, inner)
+
+	case "keyframes", "-webkit-keyframes":
+		child := ctx
+		child.selectors = nil
+		child.wrappers = append(append([]string{}, ctx.wrappers...), "@"+n.text+" "+n.value)
+		return c.compile(n.children, child, nil)
+
+	case "font-face", "page":
+		b := &cssBlock{wrappers: ctx.wrappers, selector: strings.TrimSpace("@" + n.text + " " + n.value), file: n.file, line: n.line}
+		c.blocks = append(c.blocks, b)
+		return c.compile(n.children, ctx, b)
+
+	case "charset":
+		c.raw = append([]string{"@charset " + n.value + ";"}, c.raw...)
+		return nil
+
+	case "debug", "warn":
+		fmt.Printf("%s:%d: @%s %s\n", n.file, n.line, n.text, n.value)
+		return nil
+
+	case "error":
+		return n.errorf("%s", unquote(n.value))
+	}
+
+	return n.errorf("unsupported at-rule @%s", n.text)
+}
+
+var (
+	scssVarRe    = regexp.MustCompile(`(?:[\w-]+\.)?\$([\w-]+)`)
+	scssNumberRe = regexp.MustCompile(`^(-?\d*\.?\d+)([a-zA-Z%]*)$`)
+	scssDivRe    = regexp.MustCompile(`math\.div\(\s*([^,()]+?)\s*,\s*([^,()]+?)\s*\)`)
+)
+
+// interpolate replaces #{...} in s with the evaluated expression.
+func (c *scssCompiler) interpolate(n *scssNode, s string, scope *scssScope) (string, error) {
+	for {
+		start := strings.Index(s, "#{")
+		if start < 0 {
+			return s, nil
+		}
+		end := strings.IndexByte(s[start:], '}')
+		if end < 0 {
+			return "", n.errorf("unterminated interpolation")
+		}
+		v, err := c.evaluate(n, s[start+2:start+end], scope)
+		if err != nil {
+			return "", err
+		}
+		s = s[:start] + unquote(v) + s[start+end+1:]
+	}
+}
+
+// evaluate substitutes variables in a value and folds simple arithmetic.
+func (c *scssCompiler) evaluate(n *scssNode, value string, scope *scssScope) (string, error) {
+	value, err := c.interpolate(n, value, scope)
+	if err != nil {
+		return "", err
+	}
+
+	var missing string
+	value = scssVarRe.ReplaceAllStringFunc(value, func(m string) string {
+		name := scssVarRe.FindStringSubmatch(m)[1]
+		v, ok := scope.lookup(name)
+		if !ok && missing == "" {
+			missing = name
+		}
+		return v
+	})
+	if missing != "" {
+		return "", n.errorf("undefined variable $%s", missing)
+	}
+
+	for {
+		m := scssDivRe.FindStringSubmatchIndex(value)
+		if m == nil {
+			break
+		}
+		result, ok := scssArithmetic(value[m[2]:m[3]], "/", value[m[4]:m[5]])
+		if !ok {
+			return "", n.errorf("math.div: expected numbers, found %q", value[m[0]:m[1]])
+		}
+		value = value[:m[0]] + result + value[m[1]:]
+	}
+
+	return foldArithmetic(value), nil
+}
+
+// foldArithmetic evaluates space-separated arithmetic between numbers, such
+// as "10px * 2 + 4px", leaving anything else, like "12px/1.5", untouched.
+func foldArithmetic(value string) string {
+	tokens := strings.Fields(value)
+	if len(tokens) < 3 {
+		return value
+	}
+	for _, ops := range []string{"*/", "+-"} {
+		for i := 1; i+1 < len(tokens); {
+			op := tokens[i]
+			if len(op) != 1 || !strings.Contains(ops, op) {
+				i++
+				continue
+			}
+			result, ok := scssArithmetic(tokens[i-1], op, tokens[i+1])
+			if !ok {
+				i++
+				continue
+			}
+			tokens = append(tokens[:i-1], append([]string{result}, tokens[i+2:]...)...)
+		}
+	}
+	return strings.Join(tokens, " ")
+}
+
+func scssArithmetic(a, op, b string) (string, bool) {
+	am := scssNumberRe.FindStringSubmatch(a)
+	bm := scssNumberRe.FindStringSubmatch(b)
+	if am == nil || bm == nil {
+		return "", false
+	}
+	x, _ := strconv.ParseFloat(am[1], 64)
+	y, _ := strconv.ParseFloat(bm[1], 64)
+	unit := am[2]
+	if unit == "" {
+		unit = bm[2]
+	} else if bm[2] != "" && bm[2] != unit {
+		if op == "/" {
+			unit = ""
+		} else {
+			return "", false
+		}
+	}
+
+	var r float64
+	switch op {
+	case "+":
+		r = x + y
+	case "-":
+		r = x - y
+	case "*":
+		r = x * y
+	case "/":
+		if y == 0 {
+			return "", false
+		}
+		r = x / y
+		if am[2] != "" && am[2] == bm[2] {
+			unit = ""
+		}
+	}
+	r = math.Round(r*1e5) / 1e5
+	return strconv.FormatFloat(r, 'f', -1, 64) + unit, true
+}
+
+// resolveSelectors combines a nested selector list with its parents,
+// replacing & with the parent selector.
+func resolveSelectors(parents []string, sel string) []string {
+	var out []string
+	for _, s := range splitTopLevel(sel, ',') {
+		s = strings.Join(strings.Fields(s), " ")
+		if len(parents) == 0 {
+			out = append(out, s)
+			continue
+		}
+		for _, parent := range parents {
+			if strings.Contains(s, "&") {
+				out = append(out, strings.ReplaceAll(s, "&", parent))
+			} else {
+				out = append(out, parent+" "+s)
+			}
+		}
+	}
+	return out
+}
+
+// splitTopLevel splits s on sep where it isn't inside parentheses or quotes.
+func splitTopLevel(s string, sep byte) []string {
+	var parts []string
+	depth, start := 0, 0
+	var quote byte
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'':
+			quote = c
+		case c == '(':
+			depth++
+		case c == ')':
+			depth--
+		case c == sep && depth == 0:
+			parts = append(parts, s[start:i])
+			start = i + 1
+		}
+	}
+	return append(parts, s[start:])
+}
+
+// splitCall splits "name(a, b)" into its name and arguments.
+func splitCall(s string) (string, []string) {
+	name, args, ok := strings.Cut(s, "(")
+	name = strings.TrimSpace(name)
+	if !ok {
+		return name, nil
+	}
+	args = strings.TrimSpace(args)
+	args = strings.TrimSuffix(args, ")")
+	if strings.TrimSpace(args) == "" {
+		return name, nil
+	}
+	return name, splitTopLevel(args, ',')
+}
+
+func unquote(s string) string {
+	if len(s) >= 2 && (s[0] == '"' || s[0] == '\'') && s[len(s)-1] == s[0] {
+		return s[1 : len(s)-1]
+	}
+	return s
+}
+
+// resolvePartial finds the file for an @import or @use of name relative to
+// dir, trying the partial (_name.scss) and plain forms.
+func resolvePartial(dir, name string) (string, error) {
+	base := filepath.Join(dir, filepath.FromSlash(name))
+	d, f := filepath.Split(base)
+	candidates := []string{base}
+	if filepath.Ext(base) != ".scss" {
+		candidates = []string{
+			filepath.Join(d, "_"+f+".scss"),
+			base + ".scss",
+			filepath.Join(base, "_index.scss"),
+			filepath.Join(base, "index.scss"),
+		}
+	}
+	for _, candidate := range candidates {
+		if _, err := os.Stat(candidate); err == nil {
+			return candidate, nil
+		}
+	}
+	return "", fmt.Errorf("can't find stylesheet to import: %q", name)
+}
+
+// cssWriter serialises compiled blocks and records which SCSS line each
+// output line came from.
+type cssWriter struct {
+	b        strings.Builder
+	line     int
+	mappings []cssMapping
+	sources  map[string]int
+	files    []string
+}
+
+type cssMapping struct {
+	genLine, source, srcLine int
+}
+
+func (w *cssWriter) writeLine(s, file string, line int) {
+	if file != "" {
+		idx, ok := w.sources[file]
+		if !ok {
+			idx = len(w.files)
+			w.sources[file] = idx
+			w.files = append(w.files, file)
+		}
+		w.mappings = append(w.mappings, cssMapping{genLine: w.line, source: idx, srcLine: line - 1})
+	}
+	w.b.WriteString(s)
+	w.b.WriteByte('\n')
+	w.line++
+}
+
+func (w *cssWriter) write(raw []string, blocks []*cssBlock) {
+	for _, r := range raw {
+		w.writeLine(r, "", 0)
+	}
+
+	var open []string
+	for _, b := range blocks {
+		if len(b.decls) == 0 {
+			continue
+		}
+
+		common := 0
+		for common < len(open) && common < len(b.wrappers) && open[common] == b.wrappers[common] {
+			common++
+		}
+		for len(open) > common {
+			open = open[:len(open)-1]
+			w.writeLine(strings.Repeat("  ", len(open))+"}", "", 0)
+		}
+		for _, wrapper := range b.wrappers[common:] {
+			w.writeLine(strings.Repeat("  ", len(open))+wrapper+" {", b.file, b.line)
+			open = append(open, wrapper)
+		}
+
+		indent := strings.Repeat("  ", len(open))
+		w.writeLine(indent+strings.ReplaceAll(b.selector, "\n", "\n"+indent)+" {", b.file, b.line)
+		w.line += strings.Count(b.selector, "\n")
+		for _, d := range b.decls {
+			w.writeLine(indent+"  "+d.prop+": "+d.value+";", d.file, d.line)
+		}
+		w.writeLine(indent+"}", "", 0)
+	}
+	for len(open) > 0 {
+		open = open[:len(open)-1]
+		w.writeLine(strings.Repeat("  ", len(open))+"}", "", 0)
+	}
+}
+
+// sourceMap encodes the recorded mappings as a version 3 source map.
+func (w *cssWriter) sourceMap(file string) ([]byte, error) {
+	var mappings strings.Builder
+	genLine, prevSource, prevLine := 0, 0, 0
+	for _, m := range w.mappings {
+		for genLine < m.genLine {
+			mappings.WriteByte(';')
+			genLine++
+		}
+		if mappings.Len() > 0 && mappings.String()[mappings.Len()-1] != ';' {
+			continue
+		}
+		mappings.WriteString(vlq(0) + vlq(m.source-prevSource) + vlq(m.srcLine-prevLine) + vlq(0))
+		prevSource, prevLine = m.source, m.srcLine
+	}
+
+	contents := make([]string, len(w.files))
+	sources := make([]string, len(w.files))
+	for i, f := range w.files {
+		b, err := os.ReadFile(f)
+		if err != nil {
+			return nil, err
+		}
+		contents[i] = string(b)
+		sources[i] = filepath.ToSlash(f)
+	}
+
+	return json.Marshal(map[string]any{
+		"version":        3,
+		"file":           file,
+		"sources":        sources,
+		"sourcesContent": contents,
+		"names":          []string{},
+		"mappings":       mappings.String(),
+	})
+}
+
+const base64VLQ = "ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz0123456789+/"
+
+// vlq encodes n as a base64 variable-length quantity.
+func vlq(n int) string {
+	v := n << 1
+	if n < 0 {
+		v = (-n << 1) | 1
+	}
+	var b strings.Builder
+	for {
+		digit := v & 31
+		v >>= 5
+		if v > 0 {
+			digit |= 32
+		}
+		b.WriteByte(base64VLQ[digit])
+		if v == 0 {
+			return b.String()
+		}
+	}
+}
@@ -0,0 +1,277 @@
+/*
+Copyright © 2024 Brian Greenhill <brian@briangreenhill.net>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package cmd
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// writeSCSS writes files to a temporary directory and returns the path of
+// main.scss within it.
+func writeSCSS(t *testing.T, files map[string]string) string {
+	t.Helper()
+	dir := t.TempDir()
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return filepath.Join(dir, "main.scss")
+}
+
+func TestCompileSCSS(t *testing.T) {
+	tests := []struct {
+		name  string
+		files map[string]string
+		want  string
+	}{
+		{
+			name: "variables and comments",
+			files: map[string]string{"main.scss": `
+$accent: #007acc;
+// a line comment
+/* a block comment */
+a { color: $accent; background: url(http://example.com/a.png); }
+`},
+			want: `a {
+  color: #007acc;
+  background: url(http://example.com/a.png);
+}
+`,
+		},
+		{
+			name: "default and global variables",
+			files: map[string]string{"main.scss": `
+$gap: 4px;
+$gap: 8px !default;
+a { $gap: 2px !global; }
+b { margin: $gap; }
+`},
+			want: `b {
+  margin: 2px;
+}
+`,
+		},
+		{
+			name: "nesting and parent selector",
+			files: map[string]string{"main.scss": `
+.post {
+  color: black;
+  &:hover, &.active { color: red; }
+  .title { margin: 0; }
+}
+`},
+			want: `.post {
+  color: black;
+}
+.post:hover,
+.post.active {
+  color: red;
+}
+.post .title {
+  margin: 0;
+}
+`,
+		},
+		{
+			name: "partials with use and mixins with content",
+			files: map[string]string{
+				"main.scss": `
+@use "vars";
+.card { @include card { margin: 0; } }
+`,
+				"_vars.scss": `
+$accent: #007acc !default;
+$gap: 8px;
+@mixin card($pad: $gap * 2) {
+  padding: $pad;
+  border: 1px solid $accent;
+  @content;
+}
+`,
+			},
+			want: `.card {
+  padding: 16px;
+  border: 1px solid #007acc;
+  margin: 0;
+}
+`,
+		},
+		{
+			name: "media queries bubble out with interpolation",
+			files: map[string]string{"main.scss": `
+$gap: 8px;
+.post {
+  margin: $gap * 3 auto;
+  @media (max-width: #{$gap * 100}) {
+    margin: 0;
+    a { width: math.div(100%, 3); }
+  }
+}
+`},
+			want: `.post {
+  margin: 24px auto;
+}
+@media (max-width: 800px) {
+  .post {
+    margin: 0;
+  }
+  .post a {
+    width: 33.33333%;
+  }
+}
+`,
+		},
+		{
+			name: "slashes that aren't division",
+			files: map[string]string{"main.scss": `
+a { font: 12px/1.5 sans-serif; }
+`},
+			want: `a {
+  font: 12px/1.5 sans-serif;
+}
+`,
+		},
+		{
+			name: "keyframes",
+			files: map[string]string{"main.scss": `
+@keyframes spin { from { transform: rotate(0) } to { transform: rotate(360deg) } }
+`},
+			want: `@keyframes spin {
+  from {
+    transform: rotate(0);
+  }
+  to {
+    transform: rotate(360deg);
+  }
+}
+`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			css, srcMap, err := compileSCSS(writeSCSS(t, tt.files), false)
+			if err != nil {
+				t.Fatalf("compileSCSS() error = %v", err)
+			}
+			if css != tt.want {
+				t.Errorf("compileSCSS() =\n%s\nwant\n%s", css, tt.want)
+			}
+			if srcMap != nil {
+				t.Errorf("compileSCSS() returned a source map when none was asked for")
+			}
+		})
+	}
+}
+
+func TestCompileSCSSErrors(t *testing.T) {
+	tests := []struct {
+		name  string
+		files map[string]string
+		want  string
+	}{
+		{
+			name:  "undefined variable",
+			files: map[string]string{"main.scss": "a {\n  color: $missing;\n}\n"},
+			want:  "main.scss:2:",
+		},
+		{
+			name:  "unclosed block",
+			files: map[string]string{"main.scss": "a {\n  color: red;\n"},
+			want:  "main.scss:",
+		},
+		{
+			name:  "missing partial",
+			files: map[string]string{"main.scss": "\n\n@use \"nope\";\n"},
+			want:  "main.scss:3:",
+		},
+		{
+			name:  "error in a partial",
+			files: map[string]string{"main.scss": "@use \"vars\";\n", "_vars.scss": "\n@include nope;\n"},
+			want:  "_vars.scss:2:",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, _, err := compileSCSS(writeSCSS(t, tt.files), false)
+			if err == nil {
+				t.Fatal("compileSCSS() succeeded, want an error")
+			}
+			var scssErr *scssError
+			if !errors.As(err, &scssErr) {
+				t.Errorf("compileSCSS() error = %T, want *scssError", err)
+			}
+			if !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("compileSCSS() error = %q, want it to contain %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestCompileSCSSSourceMap(t *testing.T) {
+	file := writeSCSS(t, map[string]string{
+		"main.scss":  "@use \"vars\";\na { color: $accent; }\n",
+		"_vars.scss": "$accent: red;\nb { margin: 0; }\n",
+	})
+	_, srcMap, err := compileSCSS(file, true)
+	if err != nil {
+		t.Fatalf("compileSCSS() error = %v", err)
+	}
+
+	var m struct {
+		Version  int      `json:"version"`
+		File     string   `json:"file"`
+		Sources  []string `json:"sources"`
+		Mappings string   `json:"mappings"`
+	}
+	if err := json.Unmarshal(srcMap, &m); err != nil {
+		t.Fatalf("source map isn't JSON: %v", err)
+	}
+	if m.Version != 3 || m.File != "main.css" {
+		t.Errorf("source map version %d for %q, want 3 for main.css", m.Version, m.File)
+	}
+	if len(m.Sources) != 2 {
+		t.Errorf("source map sources = %v, want main.scss and _vars.scss", m.Sources)
+	}
+	if m.Mappings == "" {
+		t.Error("source map has no mappings")
+	}
+}
+
+func TestVLQ(t *testing.T) {
+	for n, want := range map[int]string{0: "A", 1: "C", -1: "D", 15: "e", 16: "gB", -17: "jB"} {
+		if got := vlq(n); got != want {
+			t.Errorf("vlq(%d) = %q, want %q", n, got, want)
+		}
+	}
+}
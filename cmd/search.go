@@ -0,0 +1,392 @@
+/*
+Copyright © 2024 Brian Greenhill <brian@briangreenhill.net>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// Post statuses that search results can be filtered by.
+const (
+	searchStatusAll       = "all"
+	searchStatusDraft     = "draft"
+	searchStatusPublished = "published"
+)
+
+// How much a match in each part of a post counts towards its score.
+const (
+	titleWeight       = 5
+	tagWeight         = 4
+	descriptionWeight = 3
+	bodyWeight        = 1
+)
+
+const snippetWords = 20
+
+var (
+	searchTags   []string
+	searchFrom   string
+	searchTo     string
+	searchStatus string
+	searchLimit  int
+)
+
+// searchCmd represents the search command
+var searchCmd = &cobra.Command{
+	Use:   "search <query>",
+	Short: "Search the content of your posts",
+	Long: `Search the titles, tags, descriptions and text of the posts in the
+content directory, and print the best matches with a snippet of each.
+
+Every word in the query must match. Words match by prefix, so "gen" finds
+"generate" and "generating".`,
+	Args: cobra.MinimumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		var cfg config
+		if err := viper.Unmarshal(&cfg); err != nil {
+			return fmt.Errorf("error unmarshalling config: %w", err)
+		}
+
+		filter, err := newSearchFilter(searchTags, searchFrom, searchTo, searchStatus)
+		if err != nil {
+			return err
+		}
+
+		docs, err := loadSearchDocs(cmd.Context(), &cfg)
+		if err != nil {
+			return err
+		}
+
+		var matching []searchDoc
+		for _, doc := range docs {
+			if filter.match(doc.Post) {
+				matching = append(matching, doc)
+			}
+		}
+
+		query := strings.Join(args, " ")
+		results, err := newSearchIndex(matching).search(query)
+		if err != nil {
+			return err
+		}
+		if len(results) == 0 {
+			fmt.Printf("no posts match %q\n", query)
+			return nil
+		}
+
+		total := len(results)
+		if searchLimit > 0 && len(results) > searchLimit {
+			results = results[:searchLimit]
+		}
+		for _, r := range results {
+			printSearchResult(r)
+		}
+		switch {
+		case total > len(results):
+			fmt.Printf("%d of %d results\n", len(results), total)
+		case total == 1:
+			fmt.Println("1 result")
+		default:
+			fmt.Printf("%d results\n", total)
+		}
+		return nil
+	},
+}
+
+// searchDoc is a post as seen by search: its front matter and the text of
+// its rendered body.
+type searchDoc struct {
+	File string
+	Post post
+	Text string
+}
+
+// loadSearchDocs parses every post in the content directory. Posts are
+// rendered without the theme, so shortcodes are left as written.
+func loadSearchDocs(ctx context.Context, cfg *config) ([]searchDoc, error) {
+	postsDir := filepath.Join(cfg.ContentDir, postsDirName)
+	entries, err := os.ReadDir(postsDir)
+	if err != nil {
+		return nil, fmt.Errorf("error reading posts directory: %w", err)
+	}
+
+	var filenames []string
+	for _, entry := range entries {
+		if !strings.HasSuffix(entry.Name(), ".md") && !strings.HasSuffix(entry.Name(), ".markdown") {
+			continue
+		}
+		filenames = append(filenames, filepath.Join(postsDir, entry.Name()))
+	}
+
+	docs := make([]searchDoc, len(filenames))
+	errs := parallel(ctx, len(filenames), workers, func(i int) error {
+		b, err := os.ReadFile(filenames[i])
+		if err != nil {
+			return fmt.Errorf("error reading markdown file: %w", err)
+		}
+		p, err := parseMarkdown(filenames[i], b, markdownOptions{})
+		if err != nil {
+			return fmt.Errorf("error parsing %s: %w", filenames[i], err)
+		}
+		docs[i] = searchDoc{File: filenames[i], Post: p, Text: plainText(string(p.Content))}
+		return nil
+	})
+	if err := errors.Join(errs...); err != nil {
+		return nil, err
+	}
+	return docs, nil
+}
+
+// searchFilter limits search to posts with all of its tags, dated within
+// its range and with its status.
+type searchFilter struct {
+	tags     []string
+	from, to time.Time
+	status   string
+}
+
+func newSearchFilter(tags []string, from, to, status string) (searchFilter, error) {
+	f := searchFilter{status: status}
+	for _, tag := range tags {
+		f.tags = append(f.tags, strings.ToLower(tag))
+	}
+
+	var err error
+	if from != "" {
+		if f.from, err = time.Parse("2006-01-02", from); err != nil {
+			return searchFilter{}, fmt.Errorf("invalid --from date %q, expected YYYY-MM-DD", from)
+		}
+	}
+	if to != "" {
+		if f.to, err = time.Parse("2006-01-02", to); err != nil {
+			return searchFilter{}, fmt.Errorf("invalid --to date %q, expected YYYY-MM-DD", to)
+		}
+	}
+
+	switch status {
+	case searchStatusAll, searchStatusDraft, searchStatusPublished:
+	default:
+		return searchFilter{}, fmt.Errorf("invalid --status %q, expected %s, %s or %s",
+			status, searchStatusAll, searchStatusDraft, searchStatusPublished)
+	}
+	return f, nil
+}
+
+func (f searchFilter) match(p post) bool {
+	if f.status == searchStatusDraft && !p.Draft || f.status == searchStatusPublished && p.Draft {
+		return false
+	}
+
+	for _, tag := range f.tags {
+		found := false
+		for _, t := range p.Tags {
+			if strings.ToLower(t) == tag {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+
+	if f.from.IsZero() && f.to.IsZero() {
+		return true
+	}
+	// a date range leaves out posts without a date it can compare
+	date, err := time.Parse("2006-01-02", p.Date)
+	if err != nil {
+		return false
+	}
+	return (f.from.IsZero() || !date.Before(f.from)) && (f.to.IsZero() || !date.After(f.to))
+}
+
+// searchIndex is an inverted index from each stemmed word to the posts it
+// appears in, weighted by where in the post it appears.
+type searchIndex struct {
+	docs     []searchDoc
+	terms    []string // sorted, for prefix lookups
+	postings map[string]map[int]float64
+}
+
+func newSearchIndex(docs []searchDoc) *searchIndex {
+	idx := &searchIndex{docs: docs, postings: make(map[string]map[int]float64)}
+	for i, doc := range docs {
+		idx.addText(i, doc.Post.Title, titleWeight)
+		idx.addText(i, strings.Join(doc.Post.Tags, " "), tagWeight)
+		idx.addText(i, doc.Post.Description, descriptionWeight)
+		idx.addText(i, doc.Text, bodyWeight)
+	}
+	for term := range idx.postings {
+		idx.terms = append(idx.terms, term)
+	}
+	sort.Strings(idx.terms)
+	return idx
+}
+
+func (idx *searchIndex) addText(doc int, s string, weight float64) {
+	for _, w := range searchWords(s) {
+		term := stem(w)
+		if idx.postings[term] == nil {
+			idx.postings[term] = make(map[int]float64)
+		}
+		idx.postings[term][doc] += weight
+	}
+}
+
+// withPrefix returns the indexed terms that start with prefix.
+func (idx *searchIndex) withPrefix(prefix string) []string {
+	i := sort.SearchStrings(idx.terms, prefix)
+	j := i
+	for j < len(idx.terms) && strings.HasPrefix(idx.terms[j], prefix) {
+		j++
+	}
+	return idx.terms[i:j]
+}
+
+type searchResult struct {
+	Doc   searchDoc
+	Score float64
+	Terms []string
+}
+
+// search returns the posts that match every word of query, best first.
+// Each matching term counts by its weight in the post and by how rare it
+// is across all posts.
+func (idx *searchIndex) search(query string) ([]searchResult, error) {
+	var queryTerms []string
+	for _, w := range searchWords(query) {
+		queryTerms = append(queryTerms, stem(w))
+	}
+	if len(queryTerms) == 0 {
+		return nil, fmt.Errorf("query %q has no words to search for", query)
+	}
+
+	scores := make(map[int]float64)
+	matched := make(map[int]int)
+	for n, q := range queryTerms {
+		termScores := make(map[int]float64)
+		for _, term := range idx.withPrefix(q) {
+			postings := idx.postings[term]
+			idf := math.Log(1 + float64(len(idx.docs))/float64(len(postings)))
+			for doc, weight := range postings {
+				termScores[doc] += weight * idf
+			}
+		}
+		for doc, score := range termScores {
+			// only posts that matched every earlier term can still match
+			if matched[doc] == n {
+				matched[doc]++
+				scores[doc] += score
+			}
+		}
+	}
+
+	var results []searchResult
+	for doc, score := range scores {
+		if matched[doc] == len(queryTerms) {
+			results = append(results, searchResult{Doc: idx.docs[doc], Score: score, Terms: queryTerms})
+		}
+	}
+	sort.Slice(results, func(i, j int) bool {
+		if results[i].Score != results[j].Score {
+			return results[i].Score > results[j].Score
+		}
+		return results[i].Doc.File < results[j].Doc.File
+	})
+	return results, nil
+}
+
+// snippet returns the words of the post's text around the first match of
+// one of terms, or the start of the text if only its front matter matched.
+func snippet(text string, terms []string) string {
+	words := strings.Fields(text)
+	for i, w := range words {
+		if !matchesTerms(w, terms) {
+			continue
+		}
+		start := max(0, i-snippetWords/2)
+		end := min(len(words), start+snippetWords)
+		s := strings.Join(words[start:end], " ")
+		if start > 0 {
+			s = "…" + s
+		}
+		if end < len(words) {
+			s += "…"
+		}
+		return s
+	}
+	return summarize(text, snippetWords)
+}
+
+func matchesTerms(word string, terms []string) bool {
+	for _, w := range searchWords(word) {
+		for _, term := range terms {
+			if strings.HasPrefix(stem(w), term) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func printSearchResult(r searchResult) {
+	p := r.Doc.Post
+	meta := []string{p.Title}
+	if p.Date != "" {
+		meta = append(meta, p.Date)
+	}
+	if p.Draft {
+		meta = append(meta, "draft")
+	}
+	if len(p.Tags) > 0 {
+		meta = append(meta, "tags: "+strings.Join(p.Tags, ", "))
+	}
+
+	fmt.Println(r.Doc.File)
+	fmt.Println("  " + strings.Join(meta, " · "))
+	if s := snippet(r.Doc.Text, r.Terms); s != "" {
+		fmt.Println("  " + s)
+	}
+	fmt.Println()
+}
+
+func init() {
+	rootCmd.AddCommand(searchCmd)
+
+	searchCmd.Flags().StringSliceVar(&searchTags, "tag", nil, "only search posts with this tag; repeat to require several")
+	searchCmd.Flags().StringVar(&searchFrom, "from", "", "only search posts dated on or after this date (YYYY-MM-DD)")
+	searchCmd.Flags().StringVar(&searchTo, "to", "", "only search posts dated on or before this date (YYYY-MM-DD)")
+	searchCmd.Flags().StringVar(&searchStatus, "status", searchStatusAll, "only search posts with this status: all, draft or published")
+	searchCmd.Flags().IntVarP(&searchLimit, "limit", "n", 10, "maximum number of results to show; 0 shows all")
+}
@@ -0,0 +1,187 @@
+/*
+Copyright © 2024 Brian Greenhill <brian@briangreenhill.net>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"html"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// The search index and page are written to the root of the output
+// directory. The page is only rendered when the theme defines searchHTML.
+const (
+	searchIndexName = "search.json"
+	searchTemplate  = "searchHTML"
+	searchPage      = "search.html"
+	summaryWords    = 30
+)
+
+type searchConfig struct {
+	// Content adds the stemmed words of each post's body to the index, so
+	// it can be searched as well as titles, summaries and tags
+	Content bool
+}
+
+// searchEntry is one post in the search index. Tokens are the distinct
+// stemmed words of the post; see searchTokens.
+type searchEntry struct {
+	Title   string   `json:"title"`
+	URL     string   `json:"url"`
+	Date    string   `json:"date,omitempty"`
+	Summary string   `json:"summary,omitempty"`
+	Tags    []string `json:"tags,omitempty"`
+	Tokens  []string `json:"tokens,omitempty"`
+}
+
+// writeSearchIndex writes a JSON index of the site's posts for the theme's
+// search page to load.
+func writeSearchIndex(siteData siteData, output *outputFiles) error {
+	entries := []searchEntry{}
+	for _, p := range siteData.Posts {
+		text := plainText(string(p.Content))
+		entry := searchEntry{
+			Title:   p.Title,
+			URL:     postsDirName + "/" + p.Link,
+			Date:    p.Date,
+			Summary: p.Description,
+			Tags:    p.Tags,
+		}
+		if entry.Summary == "" {
+			entry.Summary = summarize(text, summaryWords)
+		}
+		if siteData.Config.Search.Content {
+			entry.Tokens = searchTokens(text)
+		}
+		entries = append(entries, entry)
+	}
+	sort.SliceStable(entries, func(i, j int) bool {
+		return entries[i].Date > entries[j].Date
+	})
+
+	b, err := json.Marshal(entries)
+	if err != nil {
+		return fmt.Errorf("error encoding search index: %w", err)
+	}
+	return output.writeFile(filepath.Join(output.dir, searchIndexName), b)
+}
+
+var (
+	htmlTag = regexp.MustCompile(`<[^>]*>`)
+	// hiddenElements hold text that isn't part of the prose: comments,
+	// scripts and styles, the # of heading permalinks, and math, whose
+	// MathML text is a jumble of symbols
+	hiddenElements = regexp.MustCompile(`(?s)<!--.*?-->` +
+		`|<(?:script|style)\b[^>]*>.*?</(?:script|style)>` +
+		`|<math\b[^>]*>.*?</math>` +
+		`|<a\b[^>]*\bclass="heading-anchor"[^>]*>.*?</a>`)
+)
+
+// plainText strips the tags from rendered HTML, leaving its visible text
+// with whitespace collapsed.
+func plainText(s string) string {
+	s = hiddenElements.ReplaceAllString(s, " ")
+	s = htmlTag.ReplaceAllString(s, " ")
+	return strings.Join(strings.Fields(html.UnescapeString(s)), " ")
+}
+
+// summarize returns the first n words of s, with an ellipsis if there are
+// more.
+func summarize(s string, n int) string {
+	words := strings.Fields(s)
+	if len(words) <= n {
+		return strings.Join(words, " ")
+	}
+	return strings.Join(words[:n], " ") + "…"
+}
+
+// stopWords are too common to be worth indexing.
+var stopWords = map[string]bool{
+	"a": true, "an": true, "and": true, "are": true, "as": true, "at": true,
+	"be": true, "but": true, "by": true, "for": true, "from": true, "if": true,
+	"in": true, "into": true, "is": true, "it": true, "its": true, "of": true,
+	"on": true, "or": true, "that": true, "the": true, "their": true,
+	"then": true, "there": true, "these": true, "this": true, "to": true,
+	"was": true, "were": true, "will": true, "with": true,
+}
+
+// searchWords splits s into lower-case words, dropping stop words and
+// single characters.
+func searchWords(s string) []string {
+	var words []string
+	for _, w := range strings.FieldsFunc(strings.ToLower(s), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	}) {
+		if len([]rune(w)) > 1 && !stopWords[w] {
+			words = append(words, w)
+		}
+	}
+	return words
+}
+
+// searchTokens returns the distinct stems of the words in s, sorted.
+func searchTokens(s string) []string {
+	seen := make(map[string]bool)
+	var tokens []string
+	for _, w := range searchWords(s) {
+		if t := stem(w); !seen[t] {
+			seen[t] = true
+			tokens = append(tokens, t)
+		}
+	}
+	sort.Strings(tokens)
+	return tokens
+}
+
+// stem removes common English suffixes so that "posts", "posted" and
+// "posting" all become "post". It's deliberately simple: search.js in the
+// default theme stems queries the same way, and matches tokens by prefix so
+// that "note" still finds "noted".
+func stem(w string) string {
+	if len(w) <= 4 {
+		return w
+	}
+	for _, suffix := range []struct{ from, to string }{
+		{"ies", "y"},
+		{"ing", ""},
+		{"ed", ""},
+		{"ly", ""},
+		{"s", ""},
+	} {
+		if !strings.HasSuffix(w, suffix.from) {
+			continue
+		}
+		if suffix.from == "s" && strings.HasSuffix(w, "ss") {
+			return w
+		}
+		if stem := strings.TrimSuffix(w, suffix.from) + suffix.to; len(stem) >= 4 {
+			return stem
+		}
+		return w
+	}
+	return w
+}
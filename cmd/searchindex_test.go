@@ -0,0 +1,71 @@
+/*
+Copyright © 2024 Brian Greenhill <brian@briangreenhill.net>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package cmd
+
+import "testing"
+
+func TestPlainText(t *testing.T) {
+	tests := []struct {
+		name string
+		html string
+		want string
+	}{
+		{
+			name: "tags and entities",
+			html: "<p>Fish &amp; <em>chips</em></p>\n<p>Peas</p>",
+			want: "Fish & chips Peas",
+		},
+		{
+			name: "heading permalink",
+			html: `<h2 id="setup">Setup <a class="heading-anchor" href="#setup" aria-label="Permalink to Setup">#</a></h2><p>Install it.</p>`,
+			want: "Setup Install it.",
+		},
+		{
+			name: "math",
+			html: `<p>Energy is <math><semantics><mrow><mi>E</mi><mo>=</mo><mi>m</mi></mrow><annotation encoding="application/x-tex">E = m</annotation></semantics></math> here.</p>`,
+			want: "Energy is here.",
+		},
+		{
+			name: "display math",
+			html: "<math display=\"block\">\n<mn>1</mn>\n</math>\n<p>After.</p>",
+			want: "After.",
+		},
+		{
+			name: "comments, scripts and styles",
+			html: `<!-- note --><style>p { color: red }</style><p>Text</p><script>alert(1)</script>`,
+			want: "Text",
+		},
+		{
+			name: "other links",
+			html: `<p>See <a class="external" href="https://example.com">the docs</a>.</p>`,
+			want: "See the docs .",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := plainText(tt.html); got != tt.want {
+				t.Errorf("plainText(%q) = %q, want %q", tt.html, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,143 @@
+/*
+Copyright © 2024 Brian Greenhill <brian@briangreenhill.net>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package cmd
+
+import (
+	"fmt"
+	"net/url"
+	"time"
+)
+
+// checkBaseURL makes sure baseURL can make the absolute og:url and og:image
+// links that social sites need to show a post's card. An empty baseURL only
+// warns, since the site still works without cards; watch builds don't need
+// one at all.
+func checkBaseURL(cfg *config) error {
+	if cfg.BaseURL == "" {
+		if !watchMode {
+			fmt.Println("warning: baseURL isn't set in the config, so social cards and SEO links use relative URLs that social sites ignore")
+		}
+		return nil
+	}
+	u, err := url.Parse(cfg.BaseURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("invalid baseURL %q: it must be an absolute http or https URL, such as https://example.com/", cfg.BaseURL)
+	}
+	return nil
+}
+
+// seoData is passed to a theme's "seo" partial. Build it in templates with
+// seoData .Config for the home page or seoData .Config .Post for a post.
+type seoData struct {
+	Config      *config
+	Post        *post
+	Title       string
+	Description string
+	URL         string
+	Image       string
+	Author      string
+	Published   string
+	// StructuredData is JSON-LD describing the page, a WebSite for the
+	// home page and a BlogPosting for posts.
+	StructuredData map[string]any
+}
+
+func newSEOData(cfg *config, posts ...post) seoData {
+	site := map[string]any{
+		"@type": "WebSite",
+		"name":  cfg.Title,
+		"url":   absURL(cfg.BaseURL, ""),
+	}
+	if cfg.Description != "" {
+		site["description"] = cfg.Description
+	}
+
+	if len(posts) == 0 {
+		site["@context"] = "https://schema.org"
+		site["author"] = person(cfg.Author, cfg.AuthorImg)
+		return seoData{
+			Config:         cfg,
+			Title:          cfg.Title,
+			Description:    cfg.Description,
+			URL:            absURL(cfg.BaseURL, ""),
+			Author:         cfg.Author,
+			StructuredData: site,
+		}
+	}
+
+	p := posts[0]
+	d := seoData{
+		Config:      cfg,
+		Post:        &p,
+		Title:       p.Title,
+		Description: p.Description,
+		URL:         absURL(cfg.BaseURL, postsDirName+"/"+p.Link),
+		Author:      p.Author,
+		Published:   p.Date,
+	}
+	if d.Description == "" {
+		d.Description = cfg.Description
+	}
+	if d.Author == "" {
+		d.Author = cfg.Author
+	}
+	if img := p.CoverImg; img != "" {
+		d.Image = absURL(cfg.BaseURL, img)
+	} else if p.CardImg != "" {
+		d.Image = absURL(cfg.BaseURL, p.CardImg)
+	}
+	if t, err := time.Parse("2006-01-02", p.Date); err == nil {
+		d.Published = t.Format(time.RFC3339)
+	}
+
+	authorImg := p.AuthorImg
+	if authorImg == "" {
+		authorImg = cfg.AuthorImg
+	}
+	posting := map[string]any{
+		"@context":         "https://schema.org",
+		"@type":            "BlogPosting",
+		"headline":         d.Title,
+		"description":      d.Description,
+		"url":              d.URL,
+		"mainEntityOfPage": d.URL,
+		"author":           person(d.Author, authorImg),
+		"isPartOf":         site,
+	}
+	if d.Published != "" {
+		posting["datePublished"] = d.Published
+	}
+	if d.Image != "" {
+		posting["image"] = d.Image
+	}
+	d.StructuredData = posting
+
+	return d
+}
+
+func person(name, image string) map[string]any {
+	p := map[string]any{"@type": "Person", "name": name}
+	if image != "" {
+		p["image"] = image
+	}
+	return p
+}
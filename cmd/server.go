@@ -0,0 +1,256 @@
+/*
+Copyright © 2024 Brian Greenhill <brian@briangreenhill.net>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package cmd
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"fmt"
+	"io"
+	"math/big"
+	"net"
+	"net/http"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const notFoundPage = "404.html"
+
+// serverOptions configures the development server.
+type serverOptions struct {
+	Bind string
+	Port int
+	TLS  bool
+}
+
+// startServer serves the site until ctx is cancelled, then shuts the
+// server down, giving open requests a few seconds to finish.
+func startServer(ctx context.Context, opts serverOptions, dir string, reload *liveReload) error {
+	mux := http.NewServeMux()
+
+	// serve files from configured output directory
+	addr := net.JoinHostPort(opts.Bind, strconv.Itoa(opts.Port))
+	var site http.FileSystem = http.Dir(dir)
+	if memoryOutput != nil {
+		site = http.FS(memoryOutput)
+	}
+	mux.Handle("/", injectLiveReload(siteHandler{site: site}))
+	mux.Handle(liveReloadPath, reload)
+	mux.Handle(draftsPath, injectLiveReload(http.HandlerFunc(serveDrafts)))
+	mux.HandleFunc(liveReloadScriptPath, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/javascript")
+		fmt.Fprint(w, liveReloadScript)
+	})
+
+	srv := &http.Server{Addr: addr, Handler: logRequests(mux)}
+	// live reload connections stay open until the browser leaves, so they
+	// have to be closed for the server to shut down
+	srv.RegisterOnShutdown(reload.close)
+
+	scheme := "http"
+	if opts.TLS {
+		cert, err := selfSignedCertificate(opts.Bind)
+		if err != nil {
+			return fmt.Errorf("error creating certificate: %w", err)
+		}
+		srv.TLSConfig = &tls.Config{Certificates: []tls.Certificate{cert}}
+		scheme = "https"
+	}
+
+	if memoryOutput != nil {
+		fmt.Println("Serving files from memory")
+	} else {
+		fmt.Println("Serving files from", dir)
+	}
+	fmt.Printf("Starting server on %s\n", addr)
+	fmt.Println()
+	host := opts.Bind
+	if host == "" || host == "0.0.0.0" || host == "::" {
+		host = "localhost"
+	}
+	fmt.Printf("Visit %s://%s to view your site\n", scheme, net.JoinHostPort(host, strconv.Itoa(opts.Port)))
+	if opts.TLS {
+		fmt.Println("The certificate is self-signed, so your browser will warn about it")
+	}
+
+	errc := make(chan error, 1)
+	go func() {
+		if opts.TLS {
+			errc <- srv.ListenAndServeTLS("", "")
+		} else {
+			errc <- srv.ListenAndServe()
+		}
+	}()
+
+	select {
+	case err := <-errc:
+		return err
+	case <-ctx.Done():
+	}
+
+	fmt.Println("Shutting down server")
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	return srv.Shutdown(shutdownCtx)
+}
+
+// siteHandler serves the generated site. Paths without an extension are
+// tried as .html pages and directories are served by their index.html, so
+// /about and /about/ both find about.html or about/index.html. Missing
+// paths get the site's 404.html.
+type siteHandler struct {
+	site http.FileSystem
+}
+
+func (h siteHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	upath := path.Clean("/" + r.URL.Path)
+
+	var candidates []string
+	if strings.HasSuffix(r.URL.Path, "/") {
+		candidates = []string{path.Join(upath, "index.html")}
+	} else {
+		candidates = []string{upath, upath + ".html"}
+		if f, ok := h.open(path.Join(upath, "index.html")); ok {
+			f.Close()
+			// redirect so that relative links on the page resolve
+			http.Redirect(w, r, upath+"/", http.StatusMovedPermanently)
+			return
+		}
+	}
+
+	for _, name := range candidates {
+		f, ok := h.open(name)
+		if !ok {
+			continue
+		}
+		defer f.Close()
+		info, err := f.Stat()
+		if err != nil {
+			break
+		}
+		http.ServeContent(w, r, name, info.ModTime(), f)
+		return
+	}
+
+	h.notFound(w, r)
+}
+
+// open opens name if it is a file rather than a directory.
+func (h siteHandler) open(name string) (http.File, bool) {
+	f, err := h.site.Open(name)
+	if err != nil {
+		return nil, false
+	}
+	if info, err := f.Stat(); err != nil || info.IsDir() {
+		f.Close()
+		return nil, false
+	}
+	return f, true
+}
+
+func (h siteHandler) notFound(w http.ResponseWriter, r *http.Request) {
+	f, ok := h.open("/" + notFoundPage)
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+	defer f.Close()
+
+	b, err := io.ReadAll(f)
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(http.StatusNotFound)
+	w.Write(b)
+}
+
+// logRequests logs the method, path, status and duration of each request.
+func logRequests(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(rec, r)
+		fmt.Printf("%s %s %d %s\n", r.Method, r.URL.RequestURI(), rec.status, time.Since(start).Round(time.Microsecond))
+	})
+}
+
+// statusRecorder records the status code of a response.
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (s *statusRecorder) WriteHeader(status int) {
+	s.status = status
+	s.ResponseWriter.WriteHeader(status)
+}
+
+// Flush lets live reload events through the recorder.
+func (s *statusRecorder) Flush() {
+	if f, ok := s.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// selfSignedCertificate creates a short-lived certificate for localhost
+// and host, for trying out features that browsers only allow over HTTPS.
+func selfSignedCertificate(host string) (tls.Certificate, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+
+	tmpl := &x509.Certificate{
+		SerialNumber: serial,
+		Subject:      pkix.Name{Organization: []string{"ssg development server"}},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(7 * 24 * time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		DNSNames:     []string{"localhost"},
+		IPAddresses:  []net.IP{net.IPv4(127, 0, 0, 1), net.IPv6loopback},
+	}
+	if ip := net.ParseIP(host); ip != nil {
+		tmpl.IPAddresses = append(tmpl.IPAddresses, ip)
+	} else if host != "" && host != "localhost" {
+		tmpl.DNSNames = append(tmpl.DNSNames, host)
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}, nil
+}
@@ -0,0 +1,362 @@
+/*
+Copyright © 2024 Brian Greenhill <brian@briangreenhill.net>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package cmd
+
+import (
+	"bytes"
+	"fmt"
+	"html/template"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+const shortcodesDirName = "shortcodes"
+
+// shortcodeSet holds the shortcode templates provided by a theme, keyed by
+// the template's file name without extension.
+type shortcodeSet struct {
+	templates  map[string]*template.Template
+	contentDir string
+}
+
+// shortcode is the data passed to a shortcode template.
+type shortcode struct {
+	Name      string
+	Args      []string
+	Params    map[string]string
+	Inner     string
+	InnerHTML template.HTML
+	Position  string
+}
+
+// Get returns a positional argument when key is an int and a named
+// parameter when key is a string, or "" if it wasn't given.
+func (s shortcode) Get(key any) string {
+	switch k := key.(type) {
+	case int:
+		if k >= 0 && k < len(s.Args) {
+			return s.Args[k]
+		}
+	case string:
+		return s.Params[k]
+	}
+	return ""
+}
+
+// loadShortcodes parses every *.html file in dir as a shortcode template.
+// A missing directory yields an empty set.
+func loadShortcodes(dir, contentDir string) (*shortcodeSet, error) {
+	set := &shortcodeSet{templates: map[string]*template.Template{}, contentDir: contentDir}
+
+	files, err := filepath.Glob(filepath.Join(dir, "*.html"))
+	if err != nil {
+		return nil, err
+	}
+
+	for _, file := range files {
+		name := strings.TrimSuffix(filepath.Base(file), filepath.Ext(file))
+		b, err := os.ReadFile(file)
+		if err != nil {
+			return nil, fmt.Errorf("error reading shortcode %s: %w", file, err)
+		}
+		tmpl, err := template.New(name).Funcs(set.funcMap()).Parse(string(b))
+		if err != nil {
+			return nil, fmt.Errorf("error parsing shortcode %s: %w", file, err)
+		}
+		set.templates[name] = tmpl
+	}
+
+	return set, nil
+}
+
+func (s *shortcodeSet) funcMap() template.FuncMap {
+	return template.FuncMap{
+		// readFile returns the contents of a file relative to the content
+		// directory, which lets shortcodes embed code samples.
+		"readFile": func(name string) (string, error) {
+			path := filepath.Join(s.contentDir, filepath.FromSlash(name))
+			rel, err := filepath.Rel(s.contentDir, path)
+			if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+				return "", fmt.Errorf("readFile: %s is outside the content directory", name)
+			}
+			b, err := os.ReadFile(path)
+			if err != nil {
+				return "", err
+			}
+			return string(b), nil
+		},
+	}
+}
+
+// shortcodeTag is a single {{< ... >}} tag found in markdown source.
+type shortcodeTag struct {
+	start, end int // byte offsets of the whole tag
+	name       string
+	closing    bool
+	selfClose  bool
+	args       string
+	escaped    bool
+}
+
+// expand replaces every shortcode in content with a placeholder and returns
+// the rendered HTML for each placeholder. Placeholders are plain
+// alphanumeric tokens so goldmark passes them through untouched; the HTML
+// is substituted back in after conversion, since safe mode would otherwise
+// strip it. firstLine is the line of content[0] in filename, for errors.
+func (s *shortcodeSet) expand(filename string, firstLine int, content []byte, opts markdownOptions) (sourceMap, map[string]template.HTML, error) {
+	if !bytes.Contains(content, []byte("{{<")) {
+		return newSourceMap(content, firstLine), nil, nil
+	}
+
+	var out bytes.Buffer
+	var anchors []sourceAnchor
+	rendered := map[string]template.HTML{}
+	pos := 0
+	for {
+		tag, err := nextShortcodeTag(content, pos)
+		if err != nil {
+			return sourceMap{}, nil, fmt.Errorf("%s:%d: %w", filename, lineAt(content, firstLine, pos), err)
+		}
+		if tag == nil {
+			break
+		}
+		line := lineAt(content, firstLine, tag.start)
+		anchors = append(anchors, sourceAnchor{offset: out.Len(), line: lineAt(content, firstLine, pos)})
+		out.Write(content[pos:tag.start])
+		pos = tag.end
+
+		if tag.escaped {
+			out.WriteString("{{<" + tag.args + ">}}")
+			continue
+		}
+		if tag.closing {
+			return sourceMap{}, nil, fmt.Errorf("%s:%d: unexpected closing shortcode %q", filename, line, tag.name)
+		}
+
+		sc := shortcode{Name: tag.name, Position: fmt.Sprintf("%s:%d", filename, line)}
+		sc.Args, sc.Params, err = parseShortcodeArgs(tag.args)
+		if err != nil {
+			return sourceMap{}, nil, fmt.Errorf("%s:%d: shortcode %q: %w", filename, line, tag.name, err)
+		}
+
+		if !tag.selfClose {
+			closer, err := findClosingShortcode(content, tag)
+			if err != nil {
+				return sourceMap{}, nil, fmt.Errorf("%s:%d: %w", filename, line, err)
+			}
+			if closer != nil {
+				inner := content[tag.end:closer.start]
+				html, err := renderMarkdown(filename, lineAt(content, firstLine, tag.end), inner, opts)
+				if err != nil {
+					return sourceMap{}, nil, err
+				}
+				sc.Inner = string(inner)
+				sc.InnerHTML = template.HTML(html)
+				pos = closer.end
+			}
+		}
+
+		tmpl, ok := s.templates[sc.Name]
+		if !ok {
+			return sourceMap{}, nil, fmt.Errorf("%s:%d: unknown shortcode %q", filename, line, sc.Name)
+		}
+		var buf bytes.Buffer
+		if err := tmpl.Execute(&buf, sc); err != nil {
+			return sourceMap{}, nil, fmt.Errorf("%s:%d: error rendering shortcode %q: %w", filename, line, sc.Name, err)
+		}
+
+		key := fmt.Sprintf("SSGSHORTCODE%dEND", len(rendered))
+		rendered[key] = template.HTML(buf.String())
+		out.WriteString(key)
+	}
+	anchors = append(anchors, sourceAnchor{offset: out.Len(), line: lineAt(content, firstLine, pos)})
+	out.Write(content[pos:])
+
+	return sourceMap{content: out.Bytes(), anchors: anchors}, rendered, nil
+}
+
+// restoreShortcodes swaps placeholders back for their rendered HTML,
+// dropping the paragraph goldmark wraps around block-level shortcodes.
+func restoreShortcodes(html string, rendered map[string]template.HTML) string {
+	for key, value := range rendered {
+		html = strings.ReplaceAll(html, "<p>"+key+"</p>", string(value))
+		html = strings.ReplaceAll(html, key, string(value))
+	}
+	return html
+}
+
+func nextShortcodeTag(content []byte, from int) (*shortcodeTag, error) {
+	i := bytes.Index(content[from:], []byte("{{<"))
+	if i < 0 {
+		return nil, nil
+	}
+	start := from + i
+	body := content[start+3:]
+
+	if trimmed := bytes.TrimLeft(body, " \t"); bytes.HasPrefix(trimmed, []byte("/*")) {
+		j := bytes.Index(body, []byte("*/>}}"))
+		if j < 0 {
+			return nil, fmt.Errorf("unclosed escaped shortcode")
+		}
+		inner := strings.TrimSpace(strings.Replace(string(body[:j]), "/*", "", 1))
+		return &shortcodeTag{start: start, end: start + 3 + j + 5, escaped: true, args: " " + inner + " "}, nil
+	}
+
+	j := bytes.Index(body, []byte(">}}"))
+	if j < 0 {
+		return nil, fmt.Errorf("unclosed shortcode")
+	}
+	tag := &shortcodeTag{start: start, end: start + 3 + j + 3}
+
+	inner := strings.TrimSpace(string(body[:j]))
+	if strings.HasPrefix(inner, "/") {
+		tag.closing = true
+		inner = strings.TrimSpace(inner[1:])
+	}
+	if strings.HasSuffix(inner, "/") {
+		tag.selfClose = true
+		inner = strings.TrimSpace(strings.TrimSuffix(inner, "/"))
+	}
+	name, args, _ := strings.Cut(inner, " ")
+	if name == "" {
+		return nil, fmt.Errorf("shortcode is missing a name")
+	}
+	tag.name = name
+	tag.args = args
+
+	return tag, nil
+}
+
+// findClosingShortcode returns the {{< /name >}} tag matching open, or nil
+// if the shortcode has no inner content.
+func findClosingShortcode(content []byte, open *shortcodeTag) (*shortcodeTag, error) {
+	depth := 0
+	pos := open.end
+	for {
+		tag, err := nextShortcodeTag(content, pos)
+		if err != nil || tag == nil {
+			return nil, err
+		}
+		pos = tag.end
+		if tag.escaped || tag.name != open.name || tag.selfClose {
+			continue
+		}
+		if !tag.closing {
+			depth++
+			continue
+		}
+		if depth == 0 {
+			return tag, nil
+		}
+		depth--
+	}
+}
+
+// parseShortcodeArgs splits args into positional arguments and key=value
+// parameters. Values may be double quoted to include spaces.
+func parseShortcodeArgs(args string) ([]string, map[string]string, error) {
+	var positional []string
+	named := map[string]string{}
+
+	s := strings.TrimSpace(args)
+	for s != "" {
+		var key, value string
+		var err error
+
+		if eq := strings.IndexAny(s, "= \t\""); eq > 0 && s[eq] == '=' {
+			key = s[:eq]
+			s = s[eq+1:]
+		}
+
+		value, s, err = readShortcodeValue(s)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		if key != "" {
+			named[key] = value
+		} else {
+			positional = append(positional, value)
+		}
+		s = strings.TrimSpace(s)
+	}
+
+	return positional, named, nil
+}
+
+func readShortcodeValue(s string) (string, string, error) {
+	if !strings.HasPrefix(s, `"`) {
+		value, rest, _ := strings.Cut(s, " ")
+		return value, rest, nil
+	}
+
+	for i := 1; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			i++
+		case '"':
+			value, err := strconv.Unquote(s[:i+1])
+			if err != nil {
+				return "", "", fmt.Errorf("invalid quoted argument %s", s[:i+1])
+			}
+			return value, s[i+1:], nil
+		}
+	}
+
+	return "", "", fmt.Errorf("unterminated quoted argument")
+}
+
+// sourceMap maps offsets in markdown that has had its shortcodes expanded
+// back to lines in the original file.
+type sourceMap struct {
+	content []byte
+	anchors []sourceAnchor
+}
+
+// sourceAnchor records that the byte at offset was on line in the original.
+type sourceAnchor struct {
+	offset, line int
+}
+
+func newSourceMap(content []byte, firstLine int) sourceMap {
+	return sourceMap{content: content, anchors: []sourceAnchor{{offset: 0, line: firstLine}}}
+}
+
+// line returns the original line of the byte at offset.
+func (m sourceMap) line(offset int) int {
+	anchor := m.anchors[0]
+	for _, a := range m.anchors {
+		if a.offset > offset {
+			break
+		}
+		anchor = a
+	}
+	return lineAt(m.content[anchor.offset:], anchor.line, offset-anchor.offset)
+}
+
+// lineAt returns the line number of offset within content, where the first
+// byte of content is on line firstLine.
+func lineAt(content []byte, firstLine, offset int) int {
+	return firstLine + bytes.Count(content[:offset], []byte("\n"))
+}
@@ -0,0 +1,103 @@
+/*
+Copyright © 2024 Brian Greenhill <brian@briangreenhill.net>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"html/template"
+	"io"
+	"path/filepath"
+	"regexp"
+	"strconv"
+)
+
+// templateError is an error parsing or executing a theme template, located
+// by the file and line it happened at.
+type templateError struct {
+	File string // theme file the template was defined in
+	Name string // template name, which differs from File for defined templates
+	Line int
+	Msg  string
+	err  error
+}
+
+func (e *templateError) Error() string {
+	if e.Name != "" && e.Name != filepath.Base(e.File) {
+		return fmt.Sprintf("%s:%d: in template %q: %s", e.File, e.Line, e.Name, e.Msg)
+	}
+	return fmt.Sprintf("%s:%d: %s", e.File, e.Line, e.Msg)
+}
+
+func (e *templateError) Unwrap() error {
+	return e.err
+}
+
+// templateErrorRe matches the "template: name:line[:col]: message" form
+// that text/template and html/template errors take.
+var templateErrorRe = regexp.MustCompile(`^(?:html/)?template: ?([^:]+):(\d+)(?::\d+)?:\s*(.*)$`)
+
+// loadTemplates parses every template in the theme directory.
+func loadTemplates(themeDir string, funcMap template.FuncMap) (*template.Template, error) {
+	tmpl, err := template.New("baseHTML").Funcs(funcMap).ParseGlob(filepath.Join(themeDir, "*.html"))
+	if err != nil {
+		return nil, newTemplateError(themeDir, nil, err)
+	}
+	return tmpl, nil
+}
+
+// executeTemplate executes the named template, returning a templateError
+// if it fails.
+func executeTemplate(tmpl *template.Template, themeDir string, w io.Writer, name string, data any) error {
+	if err := tmpl.ExecuteTemplate(w, name, data); err != nil {
+		return newTemplateError(themeDir, tmpl, err)
+	}
+	return nil
+}
+
+// newTemplateError converts an error from html/template into a
+// templateError. When tmpl is set, the name in the error is looked up to
+// find the file that defined it. Errors that don't name a template are
+// returned unchanged.
+func newTemplateError(themeDir string, tmpl *template.Template, err error) error {
+	var escapeErr *template.Error
+	if errors.As(err, &escapeErr) && escapeErr.Name != "" {
+		return templateFileError(themeDir, tmpl, escapeErr.Name, escapeErr.Line, escapeErr.Description, err)
+	}
+
+	m := templateErrorRe.FindStringSubmatch(err.Error())
+	if m == nil {
+		return err
+	}
+	line, _ := strconv.Atoi(m[2])
+	return templateFileError(themeDir, tmpl, m[1], line, m[3], err)
+}
+
+func templateFileError(themeDir string, tmpl *template.Template, name string, line int, msg string, err error) error {
+	file := name
+	if tmpl != nil {
+		if t := tmpl.Lookup(name); t != nil && t.Tree != nil && t.Tree.ParseName != "" {
+			file = t.Tree.ParseName
+		}
+	}
+	return &templateError{File: filepath.Join(themeDir, file), Name: name, Line: line, Msg: msg, err: err}
+}
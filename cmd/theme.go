@@ -0,0 +1,74 @@
+/*
+Copyright © 2024 Brian Greenhill <brian@briangreenhill.net>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"gopkg.in/yaml.v3"
+)
+
+const themeConfigName = "theme.yaml"
+
+// themeConfig is read from themes/<name>/theme.yaml and lets a theme
+// describe settings that aren't templates.
+type themeConfig struct {
+	Card    cardColors     `yaml:"card"`
+	Bundles []bundleConfig `yaml:"bundles"`
+}
+
+// cardColors are the colours used when drawing social cards, as #rrggbb.
+type cardColors struct {
+	Background string `yaml:"background"`
+	Foreground string `yaml:"foreground"`
+	Accent     string `yaml:"accent"`
+}
+
+// loadThemeConfig reads the theme's theme.yaml, if it has one, filling in
+// defaults for anything it leaves out.
+func loadThemeConfig(themeDir string) (themeConfig, error) {
+	tc := themeConfig{}
+
+	b, err := os.ReadFile(filepath.Join(themeDir, themeConfigName))
+	if err != nil && !os.IsNotExist(err) {
+		return tc, fmt.Errorf("error reading theme config: %w", err)
+	}
+	if err == nil {
+		if err := yaml.Unmarshal(b, &tc); err != nil {
+			return tc, fmt.Errorf("error parsing theme config: %w", err)
+		}
+	}
+
+	if tc.Card.Background == "" {
+		tc.Card.Background = "#ffffff"
+	}
+	if tc.Card.Foreground == "" {
+		tc.Card.Foreground = "#333333"
+	}
+	if tc.Card.Accent == "" {
+		tc.Card.Accent = "#007acc"
+	}
+
+	return tc, nil
+}
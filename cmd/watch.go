@@ -22,9 +22,18 @@ THE SOFTWARE.
 package cmd
 
 import (
+	"context"
+	"errors"
 	"fmt"
-	"net/http"
+	"io/fs"
+	"os"
+	"os/signal"
 	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"syscall"
 	"time"
 
 	"github.com/fsnotify/fsnotify"
@@ -34,6 +43,19 @@ import (
 
 var cfg config
 var watchPort int
+var watchBind string
+var watchTLS bool
+
+// watchMode is set when the site is being built for the development server.
+var watchMode bool
+
+// renderToDisk makes ssg watch write builds to the output directory rather
+// than serving them from memoryOutput.
+var renderToDisk bool
+
+// memoryOutput holds the site served by ssg watch, when it isn't rendered
+// to disk. Builds write to the output directory when it is nil.
+var memoryOutput *memoryFS
 
 // watchCmd represents the watch command
 var watchCmd = &cobra.Command{
@@ -43,88 +65,382 @@ var watchCmd = &cobra.Command{
 		if err := viper.Unmarshal(&cfg); err != nil {
 			return fmt.Errorf("unable to decode into struct, %v", err)
 		}
+		watchMode = true
+		if !renderToDisk {
+			memoryOutput = newMemoryFS()
+		}
+
+		ctx, stop := signal.NotifyContext(cmd.Context(), os.Interrupt, syscall.SIGTERM)
+		defer stop()
 
 		// generate the site initially
-		if err := generateSite(&cfg); err != nil {
+		if err := generateSite(ctx, &cfg); err != nil {
 			return fmt.Errorf("error generating site: %v", err)
 		}
 
+		reload := newLiveReload()
+		w, err := newSiteWatcher(cfg, reload)
+		if err != nil {
+			return err
+		}
+		defer w.closer()
+
+		serverErr := make(chan error, 1)
 		go func() {
-			if err := startServer(watchPort); err != nil {
-				fmt.Printf("error starting server: %v", err)
+			err := startServer(ctx, serverOptions{Bind: watchBind, Port: watchPort, TLS: watchTLS}, cfg.OutputDir, reload)
+			if err != nil {
+				// without a server there is nothing to watch for
+				stop()
 			}
+			serverErr <- err
 		}()
 
-		return watchForChanges()
+		w.run(ctx)
+		if err := <-serverErr; err != nil {
+			return fmt.Errorf("error starting server: %v", err)
+		}
+		fmt.Println("Stopped watching")
+		return nil
 	},
 }
 
-func watchForChanges() error {
+// clock is the source of time for debouncing, so that it can be replaced
+// with one that doesn't wait for real time to pass.
+type clock interface {
+	After(d time.Duration) <-chan time.Time
+}
+
+type realClock struct{}
+
+func (realClock) After(d time.Duration) <-chan time.Time {
+	return time.After(d)
+}
+
+// siteWatcher watches a site's theme, content and config for changes and
+// rebuilds it. Its file events, clock and build function can be replaced
+// so that it can be driven without a real file system or real time.
+type siteWatcher struct {
+	events   <-chan fsnotify.Event
+	errors   <-chan error
+	add      func(name string) error
+	closer   func() error
+	reload   *liveReload
+	clock    clock
+	interval time.Duration
+	// build is called with each batch of changes, one at a time, and its
+	// context is cancelled when newer changes arrive
+	build func(ctx context.Context, changes []string) error
+
+	mu  sync.Mutex
+	cfg config
+}
+
+func newSiteWatcher(cfg config, reload *liveReload) (*siteWatcher, error) {
 	watcher, err := fsnotify.NewWatcher()
 	if err != nil {
-		return fmt.Errorf("error creating watcher: %v", err)
+		return nil, fmt.Errorf("error creating watcher: %v", err)
 	}
-	defer watcher.Close()
 
-	themeDir := filepath.Join("themes", cfg.Theme)
-	content := filepath.Join(cfg.ContentDir, postsDirName)
-	assets := filepath.Join(cfg.ContentDir, assetsDirName)
-	for _, dir := range []string{themeDir, content, assets} {
-		if err := watcher.Add(dir); err != nil {
-			return fmt.Errorf("error watching directory: %v", err)
+	w := &siteWatcher{
+		events:   watcher.Events,
+		errors:   watcher.Errors,
+		add:      watcher.Add,
+		closer:   watcher.Close,
+		reload:   reload,
+		clock:    realClock{},
+		interval: 500 * time.Millisecond,
+		cfg:      cfg,
+	}
+	w.build = w.rebuild
+	return w, nil
+}
+
+func (w *siteWatcher) config() config {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.cfg
+}
+
+// run watches for changes until ctx is cancelled, waiting for any build in
+// progress to stop before it returns.
+func (w *siteWatcher) run(ctx context.Context) {
+	w.addWatches()
+
+	// new directories have to be watched as soon as they appear, before
+	// debouncing can drop their create event, so that files added to them
+	// straight away are seen
+	events := make(chan fsnotify.Event)
+	go func() {
+		defer close(events)
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case event, ok := <-w.events:
+				if !ok {
+					return
+				}
+				if event.Op&fsnotify.Create == fsnotify.Create {
+					if info, err := os.Stat(event.Name); err == nil && info.IsDir() {
+						w.addRecursive(event.Name)
+					}
+				}
+				if !w.isWatchedPath(event.Name) {
+					continue
+				}
+				select {
+				case events <- event:
+				case <-ctx.Done():
+					return
+				}
+			case err, ok := <-w.errors:
+				if !ok {
+					return
+				}
+				// errors such as a queue overflow lose events but leave
+				// the watcher working, so carry on
+				fmt.Printf("error watching files: %v\n", err)
+			}
 		}
+	}()
+
+	runBuilds(ctx, debounceEvents(ctx, w.clock, w.interval, events), w.build)
+}
 
+// runBuilds calls build for each batch of changes in turn. When a batch
+// arrives while a build is running, that build is cancelled and the next
+// one covers both batches.
+func runBuilds(ctx context.Context, batches <-chan []string, build func(context.Context, []string) error) {
+	var (
+		cancel  context.CancelFunc
+		done    chan struct{}
+		running []string
+	)
+	wait := func() {
+		if done != nil {
+			<-done
+		}
 	}
 
-	debouncedChan := debounceEvents(500*time.Millisecond, watcher.Events)
 	for {
 		select {
-		case event, ok := <-debouncedChan:
+		case <-ctx.Done():
+			wait()
+			return
+		case batch, ok := <-batches:
 			if !ok {
-				return nil
+				wait()
+				return
 			}
-			fmt.Printf("Detected change in %s\n", event.Name)
-			if shouldRegenerate(event.Name) {
-				if err := generateSite(&cfg); err != nil {
-					fmt.Printf("error generating site: %v\n", err)
+			if done != nil {
+				select {
+				case <-done:
+				default:
+					fmt.Println("Cancelling build for newer changes")
+					cancel()
+					<-done
+					batch = mergeChanges(running, batch)
 				}
 			}
-		case err, ok := <-watcher.Errors:
-			if !ok {
-				return nil
-			}
-			return fmt.Errorf("error watching directory: %v", err)
+
+			var buildCtx context.Context
+			buildCtx, cancel = context.WithCancel(ctx)
+			done = make(chan struct{})
+			running = batch
+			go func(ctx context.Context, cancel context.CancelFunc, done chan struct{}, changes []string) {
+				defer close(done)
+				defer cancel()
+				if err := build(ctx, changes); err != nil && !errors.Is(err, context.Canceled) {
+					fmt.Println(err)
+				}
+			}(buildCtx, cancel, done, batch)
+		}
+	}
+}
+
+// mergeChanges returns the sorted union of two batches of changes.
+func mergeChanges(a, b []string) []string {
+	merged := slices.Concat(a, b)
+	sort.Strings(merged)
+	return slices.Compact(merged)
+}
+
+// rebuild reloads the config if it changed, then regenerates the site, or
+// only copies assets when nothing else changed, and tells browsers to
+// reload.
+func (w *siteWatcher) rebuild(ctx context.Context, changes []string) error {
+	fmt.Printf("Detected changes in %s\n", strings.Join(changes, ", "))
+	if slices.ContainsFunc(changes, isConfigFile) {
+		if err := w.reloadConfig(); err != nil {
+			return fmt.Errorf("error reloading config: %v", err)
+		}
+		fmt.Println("Reloaded config")
+		w.addWatches()
+	}
+
+	cfg := w.config()
+	// assets are fingerprinted and images resized, and pages link to the
+	// results, so a changed asset needs a full build too
+	if !slices.ContainsFunc(changes, shouldRegenerate) && !slices.ContainsFunc(changes, w.isAssetFile) {
+		return nil
+	}
+	if err := generateSite(ctx, &cfg); err != nil {
+		if errors.Is(err, context.Canceled) {
+			return err
+		}
+		return fmt.Errorf("error generating site: %v", err)
+	}
+
+	w.reload.notify(reloadEvent(changes))
+	return nil
+}
+
+// addWatches watches the theme and content directories, including every
+// directory below them, and the directory holding the config file. It is
+// safe to call again after the config changes, as watching a directory
+// twice has no effect.
+func (w *siteWatcher) addWatches() {
+	cfg := w.config()
+	for _, dir := range []string{filepath.Join("themes", cfg.Theme), cfg.ContentDir} {
+		w.addRecursive(dir)
+	}
+
+	// editors often save by replacing the file, which would end a watch on
+	// the file itself
+	if file := viper.ConfigFileUsed(); file != "" {
+		if err := w.add(filepath.Dir(file)); err != nil {
+			fmt.Printf("error watching config file: %v\n", err)
+		}
+	}
+}
+
+// addRecursive watches dir and every directory below it, skipping hidden
+// directories. Errors are logged so that one unreadable directory doesn't
+// stop the rest being watched.
+func (w *siteWatcher) addRecursive(dir string) {
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.IsDir() {
+			return nil
+		}
+		if path != dir && strings.HasPrefix(d.Name(), ".") {
+			return filepath.SkipDir
+		}
+		return w.add(path)
+	})
+	if err != nil && !os.IsNotExist(err) {
+		fmt.Printf("error watching directory %s: %v\n", dir, err)
+	}
+}
+
+// isWatchedPath reports whether a change to name should be acted on: it is
+// the config file or inside the theme or content directory. Other files
+// next to the config file are ignored.
+func (w *siteWatcher) isWatchedPath(name string) bool {
+	if isConfigFile(name) {
+		return true
+	}
+	cfg := w.config()
+	for _, dir := range []string{filepath.Join("themes", cfg.Theme), cfg.ContentDir} {
+		if rel, err := filepath.Rel(dir, name); err == nil && !strings.HasPrefix(rel, "..") {
+			return true
 		}
 	}
+	return false
+}
+
+// isAssetFile reports whether filename is directly inside the content or
+// theme assets directory, which are copied to the output as they are.
+func (w *siteWatcher) isAssetFile(filename string) bool {
+	return isAssetFile(w.config(), filename)
+}
+
+func isAssetFile(cfg config, filename string) bool {
+	dir := filepath.Clean(filepath.Dir(filename))
+	return dir == filepath.Join(cfg.ContentDir, assetsDirName) || dir == filepath.Join("themes", cfg.Theme, assetsDirName)
+}
+
+func isConfigFile(name string) bool {
+	file := viper.ConfigFileUsed()
+	return file != "" && filepath.Clean(name) == filepath.Clean(file)
+}
+
+// reloadConfig re-reads the config file.
+func (w *siteWatcher) reloadConfig() error {
+	if err := viper.ReadInConfig(); err != nil {
+		return err
+	}
+	var next config
+	if err := viper.Unmarshal(&next); err != nil {
+		return fmt.Errorf("unable to decode into struct, %v", err)
+	}
+
+	w.mu.Lock()
+	w.cfg = next
+	w.mu.Unlock()
+	return nil
 }
 
 func shouldRegenerate(filename string) bool {
 	switch filepath.Ext(filename) {
-	case ".md", ".html", ".css", ".markdown":
+	case ".md", ".html", ".css", ".js", ".markdown", ".yaml", ".yml", ".json", ".csv", ".scss":
+		return true
+	case "":
+		// most likely a directory, which may have had posts or templates
+		// moved in or out of it
 		return true
 	default:
 		return false
 	}
 }
 
-func debounceEvents(interval time.Duration, eventChan <-chan fsnotify.Event) <-chan fsnotify.Event {
-	debouncedChan := make(chan fsnotify.Event)
+// reloadEvent returns the live reload event for a batch of changes:
+// stylesheet changes can be applied without reloading the page.
+func reloadEvent(changes []string) string {
+	for _, name := range changes {
+		switch filepath.Ext(name) {
+		case ".css", ".scss":
+		default:
+			return "reload"
+		}
+	}
+	return "css"
+}
+
+// debounceEvents collects the paths of file changes until no more arrive
+// for interval, then sends them as one sorted batch.
+func debounceEvents(ctx context.Context, clk clock, interval time.Duration, eventChan <-chan fsnotify.Event) <-chan []string {
+	debouncedChan := make(chan []string)
 	go func() {
-		var lastEvent fsnotify.Event
+		defer close(debouncedChan)
+		changed := map[string]bool{}
 		for {
 			select {
+			case <-ctx.Done():
+				return
 			case event, ok := <-eventChan:
 				if !ok {
 					return
 				}
 				// check if event is a file change
 				if event.Op&fsnotify.Write == fsnotify.Write || event.Op&fsnotify.Create == fsnotify.Create || event.Op&fsnotify.Remove == fsnotify.Remove || event.Op&fsnotify.Rename == fsnotify.Rename {
-					lastEvent = event
+					changed[event.Name] = true
 				}
-			case <-time.After(interval):
-				if lastEvent.Name != "" {
-					debouncedChan <- lastEvent
-					lastEvent = fsnotify.Event{}
+			case <-clk.After(interval):
+				if len(changed) > 0 {
+					batch := make([]string, 0, len(changed))
+					for name := range changed {
+						batch = append(batch, name)
+					}
+					sort.Strings(batch)
+					select {
+					case debouncedChan <- batch:
+					case <-ctx.Done():
+						return
+					}
+					changed = map[string]bool{}
 				}
 			}
 		}
@@ -132,21 +448,10 @@ func debounceEvents(interval time.Duration, eventChan <-chan fsnotify.Event) <-c
 	return debouncedChan
 }
 
-func startServer(port int) error {
-	mux := http.NewServeMux()
-
-	// serve files from configured output directory
-	dir := viper.GetString("outputDir")
-	addr := fmt.Sprintf(":%d", port)
-	mux.Handle("/", http.FileServer(http.Dir(dir)))
-	fmt.Println("Serving files from", dir)
-	fmt.Printf("Starting server on %s\n", addr)
-	fmt.Println()
-	fmt.Printf("Visit http://localhost:%d to view your site\n", port)
-	return http.ListenAndServe(addr, mux)
-}
-
 func init() {
 	watchCmd.Flags().IntVarP(&watchPort, "port", "p", 8080, "port for the development server")
+	watchCmd.Flags().StringVar(&watchBind, "bind", "localhost", "address for the development server to listen on; use 0.0.0.0 for all interfaces")
+	watchCmd.Flags().BoolVar(&watchTLS, "tls", false, "serve over HTTPS with a self-signed certificate")
+	watchCmd.Flags().BoolVar(&renderToDisk, "render-to-disk", false, "write builds to the output directory instead of serving them from memory")
 	rootCmd.AddCommand(watchCmd)
 }
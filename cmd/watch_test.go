@@ -0,0 +1,200 @@
+/*
+Copyright © 2024 Brian Greenhill <brian@briangreenhill.net>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package cmd
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// fakeClock hands out timers that only fire when the test calls fire.
+type fakeClock struct {
+	mu     sync.Mutex
+	latest chan time.Time
+	waits  chan struct{} // receives each time After is called
+}
+
+func newFakeClock() *fakeClock {
+	return &fakeClock{waits: make(chan struct{}, 100)}
+}
+
+func (c *fakeClock) After(time.Duration) <-chan time.Time {
+	ch := make(chan time.Time, 1)
+	c.mu.Lock()
+	c.latest = ch
+	c.mu.Unlock()
+	c.waits <- struct{}{}
+	return ch
+}
+
+// waitForTimer waits until the code under test starts waiting on the clock.
+func (c *fakeClock) waitForTimer(t *testing.T) {
+	t.Helper()
+	receive(t, c.waits)
+}
+
+// fire makes the most recent timer go off, as if its interval had passed.
+func (c *fakeClock) fire() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.latest <- time.Time{}
+}
+
+func receive[T any](t *testing.T, ch <-chan T) T {
+	t.Helper()
+	select {
+	case v := <-ch:
+		return v
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting to receive")
+		var zero T
+		return zero
+	}
+}
+
+func TestDebounceEventsMergesIntoOneRebuild(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	clk := newFakeClock()
+	events := make(chan fsnotify.Event)
+	batches := debounceEvents(ctx, clk, time.Second, events)
+
+	var (
+		mu     sync.Mutex
+		builds [][]string
+	)
+	finished := make(chan struct{})
+	go func() {
+		defer close(finished)
+		runBuilds(ctx, batches, func(_ context.Context, changes []string) error {
+			mu.Lock()
+			defer mu.Unlock()
+			builds = append(builds, changes)
+			return nil
+		})
+	}()
+
+	// the debouncer starts a new timer before the first event and after
+	// each one, so only a quiet interval lets a batch through
+	clk.waitForTimer(t)
+	for _, event := range []fsnotify.Event{
+		{Name: "content/posts/b.md", Op: fsnotify.Write},
+		{Name: "content/posts/a.md", Op: fsnotify.Create},
+		{Name: "content/posts/b.md", Op: fsnotify.Write},
+		{Name: "content/posts/c.md", Op: fsnotify.Chmod},
+	} {
+		events <- event
+		clk.waitForTimer(t)
+	}
+
+	mu.Lock()
+	if len(builds) != 0 {
+		t.Errorf("built %v before the interval passed", builds)
+	}
+	mu.Unlock()
+
+	clk.fire()
+	clk.waitForTimer(t)
+	close(events)
+	receive(t, finished)
+
+	want := [][]string{{"content/posts/a.md", "content/posts/b.md"}}
+	if !slices.EqualFunc(builds, want, slices.Equal[[]string]) {
+		t.Errorf("builds = %v, want %v", builds, want)
+	}
+}
+
+func TestDebounceEventsSkipsEmptyIntervals(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	clk := newFakeClock()
+	events := make(chan fsnotify.Event)
+	batches := debounceEvents(ctx, clk, time.Second, events)
+
+	clk.waitForTimer(t)
+	clk.fire()
+	clk.waitForTimer(t)
+	close(events)
+
+	if batch, ok := <-batches; ok {
+		t.Errorf("got batch %v with no changes", batch)
+	}
+}
+
+func TestRunBuildsCancelsInFlightBuild(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	type call struct {
+		changes []string
+		err     error
+	}
+	started := make(chan []string)
+	calls := make(chan call, 2)
+	batches := make(chan []string)
+	builds := 0
+
+	finished := make(chan struct{})
+	go func() {
+		defer close(finished)
+		runBuilds(ctx, batches, func(ctx context.Context, changes []string) error {
+			// builds run one at a time, so the count needs no lock
+			builds++
+			started <- changes
+			if builds == 1 {
+				// the first build runs until it's cancelled
+				<-ctx.Done()
+			}
+			calls <- call{changes, ctx.Err()}
+			return ctx.Err()
+		})
+	}()
+
+	batches <- []string{"themes/default/post.html"}
+	receive(t, started)
+
+	batches <- []string{"content/posts/a.md"}
+	first := receive(t, calls)
+	if !errors.Is(first.err, context.Canceled) {
+		t.Errorf("first build finished with %v, want it cancelled", first.err)
+	}
+
+	want := []string{"content/posts/a.md", "themes/default/post.html"}
+	if got := receive(t, started); !slices.Equal(got, want) {
+		t.Errorf("second build got %v, want the changes of both batches %v", got, want)
+	}
+	second := receive(t, calls)
+	if second.err != nil {
+		t.Errorf("second build finished with %v, want nil", second.err)
+	}
+
+	close(batches)
+	receive(t, finished)
+}
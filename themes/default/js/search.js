@@ -0,0 +1,124 @@
+// Searches the site's search.json. The stemming and stop words match those
+// used by ssg to build the index's tokens.
+(function () {
+    var stopWords = ["a", "an", "and", "are", "as", "at", "be", "but", "by", "for",
+        "from", "if", "in", "into", "is", "it", "its", "of", "on", "or", "that",
+        "the", "their", "then", "there", "these", "this", "to", "was", "were",
+        "will", "with"];
+    var suffixes = [["ies", "y"], ["ing", ""], ["ed", ""], ["ly", ""], ["s", ""]];
+
+    function stem(w) {
+        if (w.length <= 4) {
+            return w;
+        }
+        for (var i = 0; i < suffixes.length; i++) {
+            var from = suffixes[i][0], to = suffixes[i][1];
+            if (!w.endsWith(from)) {
+                continue;
+            }
+            if (from === "s" && w.endsWith("ss")) {
+                return w;
+            }
+            var s = w.slice(0, -from.length) + to;
+            return s.length >= 4 ? s : w;
+        }
+        return w;
+    }
+
+    function words(s) {
+        return s.toLowerCase().split(/[^\p{L}\p{N}]+/u).filter(function (w) {
+            return w.length > 1 && stopWords.indexOf(w) === -1;
+        });
+    }
+
+    function matches(list, term) {
+        return list.some(function (w) {
+            return w.startsWith(term);
+        });
+    }
+
+    // score returns how well a post matches every term, or 0 if one of them
+    // doesn't match at all
+    function score(entry, terms) {
+        var title = words(entry.title).map(stem);
+        var summary = words(entry.summary || "").map(stem);
+        var tags = (entry.tags || []).map(function (t) { return t.toLowerCase(); });
+        var tokens = entry.tokens || [];
+        var total = 0;
+        for (var i = 0; i < terms.length; i++) {
+            var s = 0;
+            if (matches(title, terms[i])) s += 10;
+            if (matches(tags, terms[i])) s += 5;
+            if (matches(summary, terms[i])) s += 3;
+            if (matches(tokens, terms[i])) s += 1;
+            if (s === 0) {
+                return 0;
+            }
+            total += s;
+        }
+        return total;
+    }
+
+    function render(results, entries, query) {
+        results.textContent = "";
+        var terms = words(query).map(stem);
+        if (terms.length === 0) {
+            return;
+        }
+        var found = entries
+            .map(function (e) { return { entry: e, score: score(e, terms) }; })
+            .filter(function (r) { return r.score > 0; })
+            .sort(function (a, b) { return b.score - a.score; });
+        if (found.length === 0) {
+            var empty = document.createElement("li");
+            empty.className = "search-empty";
+            empty.textContent = "No posts found.";
+            results.appendChild(empty);
+            return;
+        }
+        found.forEach(function (r) {
+            var item = document.createElement("li");
+            item.className = "post-item";
+            var link = document.createElement("a");
+            link.className = "post-link";
+            link.href = r.entry.url;
+            link.textContent = r.entry.title;
+            var meta = document.createElement("p");
+            meta.className = "post-meta";
+            meta.textContent = r.entry.date || "";
+            var summary = document.createElement("p");
+            summary.className = "search-summary";
+            summary.textContent = r.entry.summary || "";
+            var text = document.createElement("div");
+            text.appendChild(link);
+            text.appendChild(summary);
+            item.appendChild(text);
+            item.appendChild(meta);
+            results.appendChild(item);
+        });
+    }
+
+    var form = document.getElementById("search-form");
+    var input = document.getElementById("search-input");
+    var results = document.getElementById("search-results");
+    if (!form || !input || !results) {
+        return;
+    }
+
+    fetch(form.dataset.index)
+        .then(function (res) { return res.json(); })
+        .then(function (entries) {
+            var query = new URLSearchParams(location.search).get("q") || "";
+            input.value = query;
+            render(results, entries, query);
+            input.addEventListener("input", function () {
+                var url = new URL(location.href);
+                url.searchParams.set("q", input.value);
+                history.replaceState(null, "", url);
+                render(results, entries, input.value);
+            });
+        });
+    form.addEventListener("submit", function (e) {
+        e.preventDefault();
+    });
+})();